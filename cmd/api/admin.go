@@ -0,0 +1,384 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SetRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+type SuspendUserPayload struct {
+	Reason string    `json:"reason" validate:"required,max=500"`
+	Until  time.Time `json:"until" validate:"required"`
+}
+
+type BanUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// ListUsers godoc
+//
+//	@Summary		List users
+//	@Description	list accounts, newest first, filtered by username or email, role and status
+//	@Tags			admin
+//	@Produce		json
+//	@Param			search	query		string	false	"Part of the username or email"
+//	@Param			role	query		string	false	"Role name"
+//	@Param			status	query		string	false	"Either 'active', 'inactive', 'suspended' or 'banned'"
+//	@Param			limit	query		int		false	"Users per page"		default(20)
+//	@Param			offset	query		int		false	"Offset for pagination"	default(0)
+//	@Success		200		{array}		store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	aq := store.AdminUserQuery{Limit: 20}
+
+	aq, err := aq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(aq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.List(r.Context(), aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SetUserRole godoc
+//
+//	@Summary		Change user role
+//	@Description	give a user another role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId	path	int				true	"User ID"
+//	@Param			payload	body	SetRolePayload	true	"Role name"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId}/role [put]
+func (app *application) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := app.adminTargetId(w, r)
+	if !ok {
+		return
+	}
+
+	var payload SetRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.SetRole(ctx, userId, payload.Role); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, errors.New("user or role not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, userId)
+	app.logger.Infow("user role changed", "user_id", userId, "role", payload.Role, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SuspendUser godoc
+//
+//	@Summary		Suspend user
+//	@Description	make a user read-only until the given time
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId	path	int					true	"User ID"
+//	@Param			payload	body	SuspendUserPayload	true	"Reason and end of the suspension"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId}/suspend [post]
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := app.moderationTargetId(w, r)
+	if !ok {
+		return
+	}
+
+	var payload SuspendUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if !payload.Until.After(time.Now()) {
+		app.badRequestErrorResponse(w, r, errors.New("the suspension must end in the future"))
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserFromContext(r)
+
+	if err := app.store.Users.Suspend(ctx, userId, payload.Until, payload.Reason, moderator.ID); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, userId)
+	app.logger.Infow("user suspended", "user_id", userId, "until", payload.Until, "reason", payload.Reason, "by", moderator.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BanUser godoc
+//
+//	@Summary		Ban user
+//	@Description	shut a user out of the API until reactivated. Signs the user out everywhere
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId	path	int				true	"User ID"
+//	@Param			payload	body	BanUserPayload	true	"Reason for the ban"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId}/ban [post]
+func (app *application) banUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := app.moderationTargetId(w, r)
+	if !ok {
+		return
+	}
+
+	var payload BanUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserFromContext(r)
+
+	if err := app.store.Users.Ban(ctx, userId, payload.Reason, moderator.ID); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeUserTokens(ctx, userId); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user banned", "user_id", userId, "reason", payload.Reason, "by", moderator.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReactivateUser godoc
+//
+//	@Summary		Reactivate user
+//	@Description	lift the suspension and the ban of a user
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId}/reactivate [post]
+func (app *application) reactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := app.moderationTargetId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.Reactivate(ctx, userId); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, userId)
+	app.logger.Infow("user reactivated", "user_id", userId, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser godoc
+//
+//	@Summary		Delete user
+//	@Description	delete an account together with its posts and comments
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId} [delete]
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := app.adminTargetId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.store.Users.GetById(ctx, userId); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.Delete(ctx, userId); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, userId)
+	app.logger.Infow("user deleted", "user_id", userId, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminTargetId reads the user an admin acts on. Admins cannot demote,
+// suspend or delete themselves so the last admin cannot lock everyone out.
+func (app *application) adminTargetId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return 0, false
+	}
+
+	if userId == getUserFromContext(r).ID {
+		app.badRequestErrorResponse(w, r, errors.New("admins cannot change their own account"))
+		return 0, false
+	}
+
+	return userId, true
+}
+
+// moderationTargetId reads the user a moderator suspends, bans or
+// reactivates. Only users who can also manage accounts may act on other
+// moderators.
+func (app *application) moderationTargetId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userId, ok := app.adminTargetId(w, r)
+	if !ok {
+		return 0, false
+	}
+
+	ctx := r.Context()
+
+	manager, err := app.hasPermission(ctx, getUserFromContext(r), permUsersManage)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return 0, false
+	}
+	if manager {
+		return userId, true
+	}
+
+	target, err := app.store.Users.GetById(ctx, userId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return 0, false
+	}
+
+	moderator, err := app.store.Roles.HasPermission(ctx, target.Role.Name, permUsersModerate)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return 0, false
+	}
+	if moderator {
+		app.forbiddenErrorResponse(w, r, errors.New("moderators cannot act on other moderators"))
+		return 0, false
+	}
+
+	return userId, true
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := store.Role{Name: "admin", Level: 3}
+
+	setup := func(t *testing.T, role store.Role) *store.MockUserStore {
+		mockUserStore := new(store.MockUserStore)
+		mockRoleStore := new(store.MockRolesStore)
+		app.store.Users = mockUserStore
+		app.store.Roles = mockRoleStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Role: role}, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, mock.Anything, permTwoFactorRequired).Return(false, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", mock.Anything).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "moderator", permUsersModerate).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "moderator", permUsersManage).Return(false, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "user", mock.Anything).Return(false, nil)
+		return mockUserStore
+	}
+
+	t.Run("should only let admins manage users", func(t *testing.T) {
+		mockUserStore := setup(t, store.Role{Name: "user", Level: 1})
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/admin/users", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should list users by status", func(t *testing.T) {
+		mockUserStore := setup(t, admin)
+		mockUserStore.On("List", mock.Anything, store.AdminUserQuery{Status: "suspended", Limit: 20}).Return([]store.User{{ID: 2}}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/admin/users?status=suspended", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should change the role of a user", func(t *testing.T) {
+		mockUserStore := setup(t, admin)
+		mockUserStore.On("SetRole", mock.Anything, int64(2), "moderator").Return(nil).Once()
+		mockUserStore.On("SetRole", mock.Anything, int64(2), "owner").Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/admin/users/2/role", testToken, SetRolePayload{Role: "moderator"}), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/admin/users/2/role", testToken, SetRolePayload{Role: "owner"}), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should not let admins change their own account", func(t *testing.T) {
+		mockUserStore := setup(t, admin)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/admin/users/1/role", testToken, SetRolePayload{Role: "user"}), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockUserStore.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should suspend a user until a future time", func(t *testing.T) {
+		mockUserStore := setup(t, admin)
+		until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		mockUserStore.On("Suspend", mock.Anything, int64(2), until, "spam", int64(1)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/suspend", testToken, SuspendUserPayload{Reason: "spam", Until: until}), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/suspend", testToken, SuspendUserPayload{Reason: "spam", Until: time.Now().Add(-time.Hour)}), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should ban a user and end their sessions", func(t *testing.T) {
+		mockUserStore := setup(t, admin)
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.RefreshTokens = mockRefreshTokenStore
+
+		mockUserStore.On("Ban", mock.Anything, int64(2), "spam", int64(1)).Return(nil).Once()
+		mockUserStore.On("IncrementTokenVersion", mock.Anything, int64(2)).Return(nil).Once()
+		mockRefreshTokenStore.On("RevokeAllByUser", mock.Anything, int64(2)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/ban", testToken, BanUserPayload{Reason: "spam"}), mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should let moderators suspend users but not manage them", func(t *testing.T) {
+		mockUserStore := setup(t, store.Role{Name: "moderator", Level: 2})
+		until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, Role: store.Role{Name: "user"}}, nil)
+		mockUserStore.On("Suspend", mock.Anything, int64(2), until, "spam", int64(1)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/suspend", testToken, SuspendUserPayload{Reason: "spam", Until: until}), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/admin/users/2", testToken, nil), mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should not let moderators sanction other moderators", func(t *testing.T) {
+		mockUserStore := setup(t, store.Role{Name: "moderator", Level: 2})
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, Role: store.Role{Name: "moderator"}}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/ban", testToken, BanUserPayload{Reason: "spam"}), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertNotCalled(t, "Ban", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSuspendedUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkErrorCode := func(t *testing.T, expected string, body io.Reader) {
+		var response struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != expected {
+			t.Errorf("expected the error code %q, got %q", expected, response.Code)
+		}
+	}
+
+	t.Run("should keep a suspended user to reading", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostStore := new(store.MockPostStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostStore
+
+		until := time.Now().Add(time.Hour)
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, SuspendedUntil: &until, SuspensionReason: "spam"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/me", testToken, nil), mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/posts/", testToken, nil), mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		checkErrorCode(t, errCodeAccountSuspended, rr.Body)
+		mockPostStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should let the user write again once the suspension ends", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		until := time.Now().Add(-time.Hour)
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, SuspendedUntil: &until}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/posts/", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should reject every request from a banned user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		bannedAt := time.Now().Add(-time.Hour)
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, BannedAt: &bannedAt, BanReason: "spam"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/me", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		checkErrorCode(t, errCodeAccountBanned, rr.Body)
+	})
+}
+
+func TestAdminRoles(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(t *testing.T) *store.MockRolesStore {
+		mockUserStore := new(store.MockUserStore)
+		mockRoleStore := new(store.MockRolesStore)
+		app.store.Users = mockUserStore
+		app.store.Roles = mockRoleStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Role: store.Role{Name: "admin"}}, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permRolesManage).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permTwoFactorRequired).Return(false, nil)
+		return mockRoleStore
+	}
+
+	t.Run("should grant a permission to a role", func(t *testing.T) {
+		mockRoleStore := setup(t)
+		mockRoleStore.On("Grant", mock.Anything, "editor", permPostUpdateAny).Return(nil).Once()
+		mockRoleStore.On("Grant", mock.Anything, "editor", "post.fly").Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/admin/roles/editor/permissions/post.update.any", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/admin/roles/editor/permissions/post.fly", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockRoleStore.AssertExpectations(t)
+	})
+
+	t.Run("should keep role management on the admin's own role", func(t *testing.T) {
+		mockRoleStore := setup(t)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/admin/roles/admin/permissions/roles.manage", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockRoleStore.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should not delete the built-in roles", func(t *testing.T) {
+		mockRoleStore := setup(t)
+		mockRoleStore.On("Delete", mock.Anything, "editor").Return(store.ErrorRoleInUse).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/admin/roles/moderator", testToken, nil), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/admin/roles/editor", testToken, nil), mux)
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+		mockRoleStore.AssertExpectations(t)
+	})
+}
@@ -6,16 +6,19 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"social/graph"
 	"social/internal/auth"
 	"social/internal/env"
+	"social/internal/lockout"
 	mailer "social/internal/mailer"
 	"social/internal/ratelimiter"
 	"social/internal/store"
 	"social/internal/store/cache"
 	"social/internal/store/mongodb"
+	"sync"
 	"syscall"
 	"time"
 
@@ -34,27 +37,78 @@ import (
 )
 
 type application struct {
-	config        config
-	store         store.Storage
-	mongo         mongodb.MongoStorage
-	cacheStore    cache.Storage
-	logger        *zap.SugaredLogger
-	mailer        mailer.Client
-	authenticator auth.Authenticator
-	rateLimiter   ratelimiter.Limiter
+	config            config
+	store             store.Storage
+	mongo             mongodb.MongoStorage
+	cacheStore        cache.Storage
+	logger            *zap.SugaredLogger
+	mailer            mailer.Client
+	authenticator     auth.Authenticator
+	rateLimiter       ratelimiter.Limiter
+	invitationLimiter ratelimiter.Limiter
+	oauthProviders    map[string]auth.OAuthProvider
+	accountLockout    lockout.Tracker
+	ipLockout         lockout.Tracker
+	// background tracks the work handed off by requests, which the server
+	// waits for when it shuts down
+	background sync.WaitGroup
+	// untrustedForwardOnce warns about forwarded client addresses that are
+	// ignored once
+	untrustedForwardOnce sync.Once
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	mongo       mongoConfig
-	env         string
-	apiUrl      string
-	mail        mailConfig
-	frontendURL string
-	auth        authConfig
-	redisCfg    redisConfig
-	rateLimiter ratelimiter.Config
+	addr                  string
+	db                    dbConfig
+	mongo                 mongoConfig
+	env                   string
+	apiUrl                string
+	mail                  mailConfig
+	frontendURL           string
+	auth                  authConfig
+	redisCfg              redisConfig
+	rateLimiter           ratelimiter.Config
+	invitationRateLimiter ratelimiter.Config
+	loginLockout          loginLockoutConfig
+	exports               exportConfig
+	timelines             timelineConfig
+	ranking               rankingConfig
+	// reactions are the ones users can react to posts with
+	reactions []string
+	// trustedProxies may tell the address of the client with X-Forwarded-For
+	// and X-Real-IP
+	trustedProxies []netip.Prefix
+}
+
+// rankingConfig weighs the signals of the top feed.
+type rankingConfig struct {
+	commentWeight  float64
+	reactionWeight float64
+	affinityWeight float64
+	// gravity is how fast scores decay as posts get older
+	gravity float64
+	// window and candidates bound the newest posts that get ranked
+	window     time.Duration
+	candidates int
+}
+
+type timelineConfig struct {
+	// size is how many posts a home timeline keeps
+	size int
+	// celebrityThreshold is the follower count above which the posts of an
+	// author are read with the feed instead of pushed to timelines
+	celebrityThreshold int
+}
+
+type exportConfig struct {
+	// secret signs the download links of the archives
+	secret string
+	expiry time.Duration
+}
+
+type loginLockoutConfig struct {
+	account lockout.Config
+	ip      lockout.Config
 }
 
 type redisConfig struct {
@@ -65,15 +119,28 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic     basicConfig
+	token     tokenConfig
+	twoFactor twoFactorConfig
+	oauth     []auth.OAuthProviderConfig
+}
+
+type twoFactorConfig struct {
+	issuer       string
+	challengeExp time.Duration
+	// secretKey encrypts the TOTP secrets stored with the accounts
+	secretKey string
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	aud    string
-	iss    string
+	secret           string
+	signingKeyFile   string
+	signingKeyID     string
+	verificationKeys string
+	exp              time.Duration
+	refreshExp       time.Duration
+	aud              string
+	iss              string
 }
 
 type basicConfig struct {
@@ -82,9 +149,11 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	fromEmail string
-	sendGrid  sendgridConfig
-	expiry    time.Duration
+	fromEmail           string
+	sendGrid            sendgridConfig
+	expiry              time.Duration
+	passwordResetExpiry time.Duration
+	emailChangeExpiry   time.Duration
 }
 
 type sendgridConfig struct {
@@ -122,7 +191,7 @@ func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(app.realIPMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
@@ -146,56 +215,177 @@ func (app *application) mount() http.Handler {
 
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware())
-			r.Post("/", app.createPostHandler)
+			r.With(app.requireScope(scopePostsWrite), app.denySuspended).Post("/", app.createPostHandler)
 
 			r.Route("/{postId}", func(r chi.Router) {
 				r.Use(app.postContextMiddleware)
 
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.With(app.requireScope(scopePostsRead)).Get("/", app.getPostHandler)
+				r.With(app.requireScope(scopePostsWrite), app.denySuspended).Patch("/", app.checkPostOwnership(permPostUpdateAny, app.updatePostHandler))
+				r.With(app.requireScope(scopePostsWrite)).Delete("/", app.checkPostOwnership(permPostDeleteAny, app.deletePostHandler))
 
+				r.Route("/reactions", func(r chi.Router) {
+					r.With(app.requireScope(scopePostsRead)).Get("/", app.getReactionsHandler)
+					r.With(app.requireScope(scopePostsWrite), app.denySuspended).Put("/", app.reactHandler)
+					r.With(app.requireScope(scopePostsWrite)).Delete("/", app.unreactHandler)
+				})
 			})
 		})
 
 		r.Route("/posts/{postId}/comments", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware())
-			r.Get("/", app.getCommentsHandler)
-			r.Post("/", app.createCommentHandler)
+			r.With(app.requireScope(scopeCommentsRead)).Get("/", app.getCommentsHandler)
+			r.With(app.requireScope(scopeCommentsWrite), app.denySuspended).Post("/", app.createCommentHandler)
 		})
 
 		r.Route("/comments", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware())
 
 			r.Route("/{commentId}", func(r chi.Router) {
+				r.Use(app.requireScope(scopeCommentsWrite))
 				r.Use(app.commentContextMiddleware)
-				r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
-				r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+				r.With(app.denySuspended).Patch("/", app.checkCommentOwnership(permCommentUpdateAny, app.updateCommentHandler))
+				r.Delete("/", app.checkCommentOwnership(permCommentDeleteAny, app.deleteCommentHandler))
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
+			r.With(app.AuthTokenMiddleware(), app.requireScope(scopeUsersRead)).Get("/", app.searchUsersHandler)
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/{token}", app.confirmEmailChangeHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware())
+
+				r.With(app.requireScope(scopeUsersRead)).Get("/", app.getMeHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireSession)
+
+					r.With(app.denySuspended).Patch("/", app.updateMeHandler)
+					r.Delete("/", app.deleteMeHandler)
+					r.Put("/password", app.changePasswordHandler)
+					r.Post("/email", app.changeEmailHandler)
+
+					r.Route("/2fa", func(r chi.Router) {
+						r.Post("/", app.enrollTwoFactorHandler)
+						r.Post("/confirm", app.confirmTwoFactorHandler)
+						r.Delete("/", app.disableTwoFactorHandler)
+					})
+
+					r.Route("/follow-requests", func(r chi.Router) {
+						r.Get("/", app.getFollowRequestsHandler)
+						r.Put("/{userId}", app.approveFollowRequestHandler)
+						r.Delete("/{userId}", app.rejectFollowRequestHandler)
+					})
+
+					r.Get("/blocks", app.getBlockedHandler)
+					r.Get("/mutes", app.getMutedHandler)
+
+					r.Route("/sessions", func(r chi.Router) {
+						r.Get("/", app.getSessionsHandler)
+						r.Delete("/{sessionId}", app.deleteSessionHandler)
+					})
+
+					r.Route("/api-keys", func(r chi.Router) {
+						r.Get("/", app.getAPIKeysHandler)
+						r.Post("/", app.createAPIKeyHandler)
+						r.Delete("/{keyId}", app.deleteAPIKeyHandler)
+					})
+
+					r.Route("/exports", func(r chi.Router) {
+						r.Get("/", app.getExportsHandler)
+						r.Post("/", app.requestExportHandler)
+					})
+				})
+			})
 
 			r.Route("/{userId}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/", app.getUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/followers", app.getFollowersHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/following", app.getFollowingHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/following/{targetId}", app.isFollowingHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/relationship", app.getRelationshipHandler)
+				r.With(app.requireScope(scopeUsersWrite), app.denySuspended).Put("/follow", app.followUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/block", app.blockUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unblock", app.unblockUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/mute", app.muteUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unmute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
-				r.With(app.AuthTokenMiddleware()).Get("/feed", app.getUserFeedHandler)
+				r.With(app.AuthTokenMiddleware(), app.requireScope(scopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+				r.With(app.AuthTokenMiddleware(), app.requireScope(scopeFeedRead)).Get("/explore", app.getExploreFeedHandler)
 			})
 		})
+		// the signature in the link stands in for authentication
+		r.Get("/exports/{exportId}/download", app.downloadExportHandler)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+			r.Use(app.requireSession)
+
+			r.Route("/users", func(r chi.Router) {
+				r.With(app.requirePermission(permUsersManage)).Get("/", app.listUsersHandler)
+
+				r.Route("/{userId}", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(app.requirePermission(permUsersManage))
+
+						r.Delete("/", app.deleteUserHandler)
+						r.Put("/role", app.setUserRoleHandler)
+						r.Post("/unlock", app.unlockUserHandler)
+					})
+
+					r.Group(func(r chi.Router) {
+						r.Use(app.requirePermission(permUsersModerate))
+
+						r.Post("/suspend", app.suspendUserHandler)
+						r.Post("/ban", app.banUserHandler)
+						r.Post("/reactivate", app.reactivateUserHandler)
+					})
+				})
+			})
+
+			r.Route("/roles", func(r chi.Router) {
+				r.Use(app.requirePermission(permRolesManage))
+
+				r.Get("/", app.getRolesHandler)
+				r.Post("/", app.createRoleHandler)
+				r.Delete("/{roleName}", app.deleteRoleHandler)
+				r.Put("/{roleName}/permissions/{permission}", app.grantPermissionHandler)
+				r.Delete("/{roleName}/permissions/{permission}", app.revokePermissionHandler)
+			})
+
+			r.With(app.requirePermission(permRolesManage)).Get("/permissions", app.getPermissionsHandler)
+		})
+
 		//public routes
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/token/2fa", app.verifyTwoFactorHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Put("/password/reset/{token}", app.resetPasswordHandler)
+			r.Post("/invitation/resend", app.resendInvitationHandler)
+			r.Get("/oauth/{provider}", app.oauthLoginHandler)
+			r.Get("/oauth/{provider}/callback", app.oauthCallbackHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware())
+				r.Use(app.requireSession)
+				r.Post("/logout", app.logoutHandler)
+				r.Post("/logout/all", app.logoutAllHandler)
+			})
 		})
 	})
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Handle("/graphql", app.graphqlHandler())
 	r.Handle("/playground", playground.Handler("GraphQL Playground", "/graphql"))
 
@@ -217,6 +407,11 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan struct{})
 
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	go app.runInvitationCleanup(jobsCtx)
+	go app.runSessionCleanup(jobsCtx)
+	go app.runExportWorker(jobsCtx)
+
 	go func() {
 		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			app.logger.Fatalw("server error srv.ListenAndServe()", "error", err)
@@ -234,10 +429,14 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("received shutdown signal", "signal", s)
 
+		cancelJobs()
+
 		if err := srv.Shutdown(ctx); err != nil {
 			app.logger.Errorw("server shutdown error", "error", err)
 		}
 
+		app.background.Wait()
+
 		close(shutdown)
 	}()
 
@@ -249,3 +448,20 @@ func (app *application) run(mux http.Handler) error {
 
 	return nil
 }
+
+// runInBackground runs fn without holding up the response. A panic in fn is
+// logged rather than taking the server down.
+func (app *application) runInBackground(fn func()) {
+	app.background.Add(1)
+
+	go func() {
+		defer app.background.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", err)
+			}
+		}()
+
+		fn()
+	}()
+}
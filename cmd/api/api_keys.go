@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type apiKeyKey string
+
+const apiKeyCtxKey apiKeyKey = "apiKey"
+
+// Scopes that can be granted to an API key. Session tokens are not scoped.
+const (
+	scopePostsRead     = "posts:read"
+	scopePostsWrite    = "posts:write"
+	scopeCommentsRead  = "comments:read"
+	scopeCommentsWrite = "comments:write"
+	scopeUsersRead     = "users:read"
+	scopeUsersWrite    = "users:write"
+	scopeFeedRead      = "feed:read"
+)
+
+type CreateAPIKeyPayload struct {
+	Name   string     `json:"name" validate:"required,max=100"`
+	Scopes []string   `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write comments:read comments:write users:read users:write feed:read"`
+	Expiry *time.Time `json:"expiry" validate:"omitempty"`
+}
+
+type APIKeyWithSecret struct {
+	*store.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Create API key
+//	@Description	create a named API key with scopes for the current user; the key is only returned once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAPIKeyPayload	true	"API key"
+//	@Success		201		{object}	APIKeyWithSecret
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAPIKeyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if payload.Expiry != nil && payload.Expiry.Before(time.Now()) {
+		app.badRequestErrorResponse(w, r, errors.New("expiry must be in the future"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	plainKey, hashKey, err := auth.GenerateAPIKey()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	key := &store.APIKey{
+		UserId: user.ID,
+		Name:   payload.Name,
+		Key:    hashKey,
+		Scopes: payload.Scopes,
+		Expiry: payload.Expiry,
+	}
+
+	if err := app.store.APIKeys.Create(r.Context(), key); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, &APIKeyWithSecret{APIKey: key, Key: plainKey}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	list the API keys of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.APIKey
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [get]
+func (app *application) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	keys, err := app.store.APIKeys.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, keys); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAPIKey godoc
+//
+//	@Summary		Revoke API key
+//	@Description	revoke an API key of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			keyId	path	int	true	"API key ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys/{keyId} [delete]
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyId, err := strconv.ParseInt(chi.URLParam(r, "keyId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid API key ID"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.APIKeys.Delete(r.Context(), keyId, user.ID); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateAPIKey is the ApiKey scheme of AuthTokenMiddleware.
+func (app *application) authenticateAPIKey(ctx context.Context, plainKey string) (context.Context, error) {
+	key, err := app.store.APIKeys.GetByKey(ctx, auth.HashAPIKey(plainKey))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := app.getUserWithRedis(ctx, key.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, userCtxKey, user)
+	ctx = context.WithValue(ctx, apiKeyCtxKey, key)
+	return ctx, nil
+}
+
+// requireScope rejects requests authenticated with an API key that was not
+// granted the scope. Requests with a session token pass through.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := getAPIKeyFromContext(r); key != nil && !key.HasScope(scope) {
+				app.forbiddenErrorResponse(w, r, fmt.Errorf("API key is missing the %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSession rejects requests authenticated with an API key, for routes
+// that manage the account itself.
+func (app *application) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAPIKeyFromContext(r) != nil {
+			app.forbiddenErrorResponse(w, r, errors.New("API keys cannot be used for this action"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getAPIKeyFromContext(r *http.Request) *store.APIKey {
+	key, _ := r.Context().Value(apiKeyCtxKey).(*store.APIKey)
+	return key
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should return the key once and store its hash", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockAPIKeyStore := new(store.MockAPIKeyStore)
+		app.store.Users = mockUserStore
+		app.store.APIKeys = mockAPIKeyStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockAPIKeyStore.On("Create", mock.Anything, mock.MatchedBy(func(k *store.APIKey) bool {
+			return k.UserId == 1 && k.Name == "bot" && len(k.Scopes) == 1
+		})).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/users/me/api-keys", testToken, CreateAPIKeyPayload{Name: "bot", Scopes: []string{scopePostsWrite}}), mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var body struct {
+			Data APIKeyWithSecret `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(body.Data.Key, "gbk_") {
+			t.Errorf("expected a prefixed key, got %q", body.Data.Key)
+		}
+		mockAPIKeyStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/users/me/api-keys", testToken, CreateAPIKeyPayload{Name: "bot", Scopes: []string{"admin:all"}}), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	plainKey := "gbk_test"
+
+	setup := func(t *testing.T, key *store.APIKey, err error) {
+		mockUserStore := new(store.MockUserStore)
+		mockAPIKeyStore := new(store.MockAPIKeyStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		app.store.Users = mockUserStore
+		app.store.APIKeys = mockAPIKeyStore
+		app.store.Comments = mockCommentStore
+
+		mockAPIKeyStore.On("GetByKey", mock.Anything, auth.HashAPIKey(plainKey)).Return(key, err)
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockCommentStore.On("ListByPostId", mock.Anything, int64(1), int64(1), mock.Anything).Return([]store.Comment{}, nil)
+	}
+
+	newRequest := func(t *testing.T, method, path string) *http.Request {
+		req := newJSONRequest(t, method, path, nil)
+		req.Header.Set("Authorization", "ApiKey "+plainKey)
+		return req
+	}
+
+	t.Run("should allow a route within the key's scopes", func(t *testing.T) {
+		setup(t, &store.APIKey{ID: 1, UserId: 1, Scopes: []string{scopeCommentsRead}}, nil)
+
+		rr := executeRequest(newRequest(t, http.MethodGet, "/v1/posts/1/comments"), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should forbid a route outside the key's scopes", func(t *testing.T) {
+		setup(t, &store.APIKey{ID: 1, UserId: 1, Scopes: []string{scopePostsWrite}}, nil)
+
+		rr := executeRequest(newRequest(t, http.MethodGet, "/v1/posts/1/comments"), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should not manage keys with a key", func(t *testing.T) {
+		setup(t, &store.APIKey{ID: 1, UserId: 1, Scopes: []string{scopeUsersWrite}}, nil)
+
+		rr := executeRequest(newRequest(t, http.MethodGet, "/v1/users/me/api-keys"), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should reject an unknown or expired key", func(t *testing.T) {
+		setup(t, nil, store.ErrorNotFound)
+
+		rr := executeRequest(newRequest(t, http.MethodGet, "/v1/posts/1/comments"), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
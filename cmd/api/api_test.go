@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"social/internal/ratelimiter"
 	"testing"
 	"time"
@@ -16,6 +17,8 @@ func TestRateLimiterMiddleware(t *testing.T) {
 			Enabled:              true,
 		},
 		addr: ":8080",
+		// the requests come through the proxy on the loopback address
+		trustedProxies: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")},
 	}
 
 	app := newTestApplication(t, cfg)
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	mailer "social/internal/mailer"
 	"social/internal/store"
@@ -87,6 +89,23 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		User:  user,
 		Token: plainToken,
 	}
+
+	err = app.sendInvitation(user, plainToken)
+	if err != nil {
+		app.logger.Errorw("failed to send email", "error", err.Error())
+		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
+			app.logger.Errorw("failed to delete user", "error", err.Error())
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusCreated, userWithToken); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) sendInvitation(user *store.User, plainToken string) error {
 	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
 
 	isProdEnv := app.config.env == "production"
@@ -98,25 +117,13 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		ActivationURL: activationURL,
 	}
 
-	err = app.mailer.Send(
+	return app.mailer.Send(
 		mailer.UserWelcome,
 		user.Username,
 		user.Email,
 		vars,
 		!isProdEnv,
 	)
-	if err != nil {
-		app.logger.Errorw("failed to send email", "error", err.Error())
-		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
-			app.logger.Errorw("failed to delete user", "error", err.Error())
-		}
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err = app.jsonResponse(w, http.StatusCreated, userWithToken); err != nil {
-		app.internalServerError(w, r, err)
-	}
 }
 
 type CreateUserTokenPayload struct {
@@ -124,17 +131,26 @@ type CreateUserTokenPayload struct {
 	Password string `json:"password" validate:"required,min=3,max=72"`
 }
 
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
 // CreateToken godoc
 //
 //	@Summary		Create token
-//	@Description	create token
+//	@Description	create an access token and a refresh token, or a challenge when two-factor authentication is enabled
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			user	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		201		string		"Token"
+//	@Success		201		{object}	AuthTokens
+//	@Success		202		{object}	TwoFactorChallenge
 //	@Failure		400		{object}	error
-//	@Failure		409		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		429		{object}	error
 //	@Failure		500		{object}	error
 //
 //	@Router			/authentication/token [post]
@@ -150,36 +166,370 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	ctx := r.Context()
+
+	lockedFor, err := app.loginLockedFor(ctx, r, payload.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if lockedFor > 0 {
+		app.accountLockedResponse(w, r, lockedFor)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrorNotFound:
+			app.recordLoginFailure(ctx, r, payload.Email, nil)
 			app.unAuthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
+		return
 	}
 
 	if !user.Password.VerifyPassword(payload.Password) {
+		app.recordLoginFailure(ctx, r, payload.Email, user)
 		app.unAuthorizedErrorResponse(w, r, errors.New("invalid password"))
 		return
 	}
 
+	app.resetLoginFailures(ctx, payload.Email)
+
+	if user.IsBanned() {
+		app.accountBannedResponse(w, r, user)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		app.createTwoFactorChallenge(w, r, user)
+		return
+	}
+
+	app.issueAuthTokens(w, r, user)
+}
+
+// issueAuthTokens starts a new session, whose id is the id of its refresh
+// token family, and responds with the first token pair.
+func (app *application) issueAuthTokens(w http.ResponseWriter, r *http.Request, user *store.User) {
+	ctx := r.Context()
+
+	session := app.newSession(r, user.ID)
+	if err := app.store.Sessions.Create(ctx, session); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainRefreshToken, refreshToken, err := app.newRefreshToken(user.ID, session.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.RefreshTokens.Create(ctx, refreshToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.newAuthTokens(user, session.ID, plainRefreshToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// RefreshToken godoc
+//
+//	@Summary		Refresh token
+//	@Description	exchange a refresh token for a new access token and a rotated refresh token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	AuthTokens
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := app.store.RefreshTokens.GetByToken(ctx, app.authenticator.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if current.RevokedAt != nil {
+		app.revokeRefreshTokenFamily(w, r, current)
+		return
+	}
+
+	if time.Now().After(current.Expiry) {
+		app.unAuthorizedErrorResponse(w, r, errors.New("refresh token expired"))
+		return
+	}
+
+	user, err := app.store.Users.GetById(ctx, current.UserId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.IsBanned() {
+		app.accountBannedResponse(w, r, user)
+		return
+	}
+
+	plainRefreshToken, next, err := app.newRefreshToken(user.ID, current.FamilyId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.RefreshTokens.Rotate(ctx, current, next); err != nil {
+		switch err {
+		case store.ErrorTokenReused:
+			app.revokeRefreshTokenFamily(w, r, current)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.newAuthTokens(user, current.FamilyId, plainRefreshToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revokeRefreshTokenFamily handles the reuse of an already rotated refresh
+// token. Reuse means the token leaked, so every token of the family is revoked
+// and the session ends.
+func (app *application) revokeRefreshTokenFamily(w http.ResponseWriter, r *http.Request, token *store.RefreshToken) {
+	app.logger.Warnw("refresh token reuse detected", "user_id", token.UserId, "family_id", token.FamilyId)
+
+	ctx := r.Context()
+
+	if err := app.store.RefreshTokens.RevokeFamily(ctx, token.FamilyId); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Sessions.Delete(ctx, token.FamilyId, token.UserId); err != nil && err != store.ErrorNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.unAuthorizedErrorResponse(w, r, store.ErrorTokenReused)
+}
+
+func (app *application) newRefreshToken(userId int64, familyId string) (string, *store.RefreshToken, error) {
+	plainToken, hashToken, err := app.authenticator.GenerateRefreshToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plainToken, &store.RefreshToken{
+		UserId:   userId,
+		FamilyId: familyId,
+		Token:    hashToken,
+		Expiry:   time.Now().Add(app.config.auth.token.refreshExp),
+	}, nil
+}
+
+func (app *application) newAuthTokens(user *store.User, sessionId, refreshToken string) (*AuthTokens, error) {
 	claims := jwt.MapClaims{
 		"sub": user.ID,
+		"sid": sessionId,
+		"jti": uuid.New().String(),
+		"ver": user.TokenVersion,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.aud,
 	}
-	token, err := app.authenticator.GenerateToken(claims)
+	accessToken, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}, nil
+}
+
+type claimsKey string
+
+const claimsCtxKey claimsKey = "claims"
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"omitempty,max=255"`
+}
+
+// Logout godoc
+//
+//	@Summary		Logout
+//	@Description	revoke the current access token, end its session and, when given, revoke the refresh token family
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	LogoutPayload	false	"Refresh token to revoke"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload LogoutPayload
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	claims := getClaimsFromContext(r)
+	if err := app.revokeToken(ctx, claims); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if err := app.store.Sessions.Delete(ctx, sid, user.ID); err != nil && err != store.ErrorNotFound {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if payload.RefreshToken != "" {
+		token, err := app.store.RefreshTokens.GetByToken(ctx, app.authenticator.HashRefreshToken(payload.RefreshToken))
+		switch {
+		case err == nil && token.UserId == user.ID:
+			if err := app.store.RefreshTokens.RevokeFamily(ctx, token.FamilyId); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		case err != nil && err != store.ErrorNotFound:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+//
+//	@Summary		Logout everywhere
+//	@Description	revoke every access and refresh token issued to the current user
+//	@Tags			auth
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout/all [post]
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	if err := app.revokeUserTokens(ctx, user.ID); err != nil {
 		app.internalServerError(w, r, err)
+		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeToken puts the token id on the revocation list until the token
+// would have expired on its own.
+func (app *application) revokeToken(ctx context.Context, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	exp, _ := claims["exp"].(float64)
+	expiry := time.Unix(int64(exp), 0)
+
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.Revoke(ctx, jti, expiry)
+	}
+
+	return app.cacheStore.RevokedTokens.Revoke(ctx, jti, time.Until(expiry))
+}
+
+// revokeUserTokens bumps the user's token version, which invalidates all of
+// their access tokens at once, revokes their refresh tokens and ends their
+// sessions.
+func (app *application) revokeUserTokens(ctx context.Context, userId int64) error {
+	if err := app.store.Users.IncrementTokenVersion(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := app.store.RefreshTokens.RevokeAllByUser(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := app.store.Sessions.DeleteAllByUser(ctx, userId); err != nil {
+		return err
+	}
+
+	app.invalidateUserCache(ctx, userId)
+	return nil
+}
+
+func getClaimsFromContext(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtxKey).(jwt.MapClaims)
+	return claims
 }
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"social/internal/auth"
+	"social/internal/lockout"
 	mailer "social/internal/mailer"
 	"social/internal/store"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -95,6 +100,10 @@ func TestCreateToken(t *testing.T) {
 	mux := app.mount()
 
 	t.Run("should create a new token", func(t *testing.T) {
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.RefreshTokens = mockRefreshTokenStore
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
 		var hashedPassword store.Password
 		err := hashedPassword.Set("test")
 		if err != nil {
@@ -126,6 +135,34 @@ func TestCreateToken(t *testing.T) {
 
 		t.Logf("Parsed Response: %+v", responseBody)
 		checkResponseCode(t, http.StatusCreated, rr.Code)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should sign the token version of a user who revoked their tokens", func(t *testing.T) {
+		app := newTestApplication(t, config{
+			auth: authConfig{token: tokenConfig{exp: time.Hour, aud: "test", iss: "test"}},
+		})
+		app.authenticator = auth.NewJWTAuthenticator("test", "test", "test")
+		mux := app.mount()
+
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = versionedUserStore{MockUserStore: mockUserStore, version: 2}
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, TokenVersion: 2}, nil)
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{}, nil)
+		app.store.RefreshTokens.(*store.MockRefreshTokenStore).On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/token", CreateUserTokenPayload{Email: "test@test.com", Password: "test"}), mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var response struct {
+			Data AuthTokens `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/1", response.Data.AccessToken, nil), mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
 	})
 
 	t.Run("should not create a new token. Invalid Password", func(t *testing.T) {
@@ -166,3 +203,258 @@ func TestCreateToken(t *testing.T) {
 		}
 	})
 }
+
+func TestRefreshToken(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: false,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	mux := app.mount()
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		mockUserStore := new(store.MockUserStore)
+		app.store.RefreshTokens = mockRefreshTokenStore
+		app.store.Users = mockUserStore
+
+		current := &store.RefreshToken{
+			ID:       1,
+			UserId:   1,
+			FamilyId: "family",
+			Expiry:   time.Now().Add(time.Hour),
+		}
+
+		mockRefreshTokenStore.On("GetByToken", mock.Anything, app.authenticator.HashRefreshToken("plain")).Return(current, nil).Once()
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil).Once()
+		mockRefreshTokenStore.On("Rotate", mock.Anything, current, mock.MatchedBy(func(next *store.RefreshToken) bool {
+			return next.FamilyId == "family" && next.UserId == 1
+		})).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/refresh", RefreshTokenPayload{RefreshToken: "plain"}), mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var response struct {
+			Data AuthTokens `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data.RefreshToken == "" || response.Data.RefreshToken == "plain" {
+			t.Errorf("expected a new refresh token, got %q", response.Data.RefreshToken)
+		}
+
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should revoke the family when a rotated token is reused", func(t *testing.T) {
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.RefreshTokens = mockRefreshTokenStore
+
+		revokedAt := time.Now().Add(-time.Minute)
+		current := &store.RefreshToken{
+			ID:        1,
+			UserId:    1,
+			FamilyId:  "family",
+			Expiry:    time.Now().Add(time.Hour),
+			RevokedAt: &revokedAt,
+		}
+
+		mockRefreshTokenStore.On("GetByToken", mock.Anything, mock.Anything).Return(current, nil).Once()
+		mockRefreshTokenStore.On("RevokeFamily", mock.Anything, "family").Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/refresh", RefreshTokenPayload{RefreshToken: "plain"}), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should not accept an unknown refresh token", func(t *testing.T) {
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.RefreshTokens = mockRefreshTokenStore
+
+		mockRefreshTokenStore.On("GetByToken", mock.Anything, mock.Anything).Return(nil, store.ErrorNotFound).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/refresh", RefreshTokenPayload{RefreshToken: "unknown"}), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockRefreshTokenStore.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: false,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should revoke the current token", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockRevokedTokenStore := new(store.MockRevokedTokenStore)
+		app.store.Users = mockUserStore
+		app.store.RevokedTokens = mockRevokedTokenStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil).Once()
+		mockRevokedTokenStore.On("IsRevoked", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRevokedTokenStore.On("Revoke", mock.Anything, "00000000-0000-0000-0000-000000000001", mock.Anything).Return(nil).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/authentication/logout", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockRevokedTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject a revoked token", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockRevokedTokenStore := new(store.MockRevokedTokenStore)
+		app.store.Users = mockUserStore
+		app.store.RevokedTokens = mockRevokedTokenStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil).Once()
+		mockRevokedTokenStore.On("IsRevoked", mock.Anything, mock.Anything).Return(true, nil).Once()
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/1", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should reject a token issued before logging out everywhere", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, TokenVersion: 1}, nil).Once()
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/1", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should revoke every token of the user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.Users = mockUserStore
+		app.store.RefreshTokens = mockRefreshTokenStore
+		app.store.RevokedTokens = new(store.MockRevokedTokenStore)
+		app.store.RevokedTokens.(*store.MockRevokedTokenStore).On("IsRevoked", mock.Anything, mock.Anything).Return(false, nil)
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil).Once()
+		mockUserStore.On("IncrementTokenVersion", mock.Anything, int64(1)).Return(nil).Once()
+		mockRefreshTokenStore.On("RevokeAllByUser", mock.Anything, int64(1)).Return(nil).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/authentication/logout/all", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newTestApplication(t, config{
+		loginLockout: loginLockoutConfig{
+			account: lockout.Config{Threshold: 2, BaseDuration: time.Minute, MaxDuration: time.Hour, Window: time.Hour},
+		},
+	})
+	mux := app.mount()
+
+	login := func(t *testing.T, password string) *httptest.ResponseRecorder {
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token", CreateUserTokenPayload{Email: "K7iGd@example.com", Password: password})
+		return executeRequest(req, mux)
+	}
+
+	mockMailer := new(mailer.MockMailer)
+	mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+	app.mailer = mockMailer
+	app.store.RefreshTokens = mockRefreshTokenStore
+
+	t.Run("should lock the account and notify the owner", func(t *testing.T) {
+		mockMailer.On("Send", mailer.AccountLocked, "test", "K7iGd@example.com", mock.Anything, true).Return(nil).Once()
+
+		checkResponseCode(t, http.StatusUnauthorized, login(t, "wrong").Code)
+		checkResponseCode(t, http.StatusUnauthorized, login(t, "wrong").Code)
+
+		rr := login(t, "test")
+		checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+		if rr.Header().Get("Retry-After") != "60" {
+			t.Errorf("expected Retry-After 60, got %q", rr.Header().Get("Retry-After"))
+		}
+		app.background.Wait()
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should let an admin unlock the account", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockRoleStore := new(store.MockRolesStore)
+		app.store.Users = mockUserStore
+		app.store.Roles = mockRoleStore
+
+		admin := store.Role{Name: "admin", Level: 3}
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Role: admin}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, Email: "k7igd@example.com"}, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permUsersManage).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permTwoFactorRequired).Return(false, nil)
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/admin/users/2/unlock", testToken, nil)
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+		checkResponseCode(t, http.StatusCreated, login(t, "test").Code)
+	})
+}
+
+func TestLoginLockoutByAddress(t *testing.T) {
+	app := newTestApplication(t, config{
+		loginLockout: loginLockoutConfig{
+			ip: lockout.Config{Threshold: 2, BaseDuration: time.Minute, MaxDuration: time.Hour, Window: time.Hour},
+		},
+	})
+	mux := app.mount()
+
+	login := func(t *testing.T, forwardedFor string) *httptest.ResponseRecorder {
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token", CreateUserTokenPayload{Email: "K7iGd@example.com", Password: "wrong"})
+		req.RemoteAddr = "203.0.113.7:41000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should not let the client pick the address it is locked out by", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, login(t, "198.51.100.1").Code)
+		checkResponseCode(t, http.StatusUnauthorized, login(t, "198.51.100.2").Code)
+		checkResponseCode(t, http.StatusTooManyRequests, login(t, "198.51.100.3").Code)
+	})
+}
+
+// versionedUserStore logs in a user whose tokens have been revoked before.
+type versionedUserStore struct {
+	*store.MockUserStore
+	version int
+}
+
+func (s versionedUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	user, err := s.MockUserStore.GetByEmail(ctx, email)
+	user.TokenVersion = s.version
+	return user, err
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Block user
+//	@Description	block a user. Follows between the two users end, and neither sees the other's posts and comments
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Block, "users cannot block themselves")
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblock user
+//	@Description	lift a block. Follows ended by the block are not restored
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unblock, "users cannot unblock themselves")
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mute user
+//	@Description	hide the posts of a user from the current user's feed
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Mutes.Mute, "users cannot mute themselves")
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmute user
+//	@Description	show the posts of a muted user in the feed again
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Mutes.Unmute, "users cannot unmute themselves")
+}
+
+func (app *application) changeRelation(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userId, otherId int64) error, selfMessage string) {
+	otherId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	if otherId == user.ID {
+		app.badRequestErrorResponse(w, r, errors.New(selfMessage))
+		return
+	}
+
+	if err := change(r.Context(), user.ID, otherId); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		case store.ErrorAlreadyExists:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBlocked godoc
+//
+//	@Summary		List blocked users
+//	@Description	list the users the current user blocked, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.UserEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) getBlockedHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeUserList(w, r, app.store.Blocks.GetBlocked)
+}
+
+// GetMuted godoc
+//
+//	@Summary		List muted users
+//	@Description	list the users the current user muted, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.UserEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) getMutedHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeUserList(w, r, app.store.Mutes.GetMuted)
+}
+
+func (app *application) writeUserList(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, userId int64, q store.PaginatedKeysetQuery) ([]store.UserEntry, error)) {
+	q, err := parseKeysetQuery(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	// one extra row tells whether there is another page
+	limit := q.Limit
+	q.Limit++
+	entries, err := list(r.Context(), getUserFromContext(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	users, page := paginate(entries, limit, q.Cursor, false)
+
+	if err := app.pagedJSONResponse(w, r, http.StatusOK, users, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
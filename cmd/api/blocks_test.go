@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBlockUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow blocking yourself", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockBlockStore := new(store.MockBlockStore)
+		app.store.Users = mockUserStore
+		app.store.Blocks = mockBlockStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/1/block", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockBlockStore.AssertNotCalled(t, "Block", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should block and unblock a user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockBlockStore := new(store.MockBlockStore)
+		app.store.Users = mockUserStore
+		app.store.Blocks = mockBlockStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockBlockStore.On("Block", mock.Anything, int64(1), int64(2)).Return(nil).Once()
+		mockBlockStore.On("Unblock", mock.Anything, int64(1), int64(2)).Return(nil).Once()
+		mockBlockStore.On("Unblock", mock.Anything, int64(1), int64(3)).Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/2/block", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/2/unblock", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/3/unblock", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockBlockStore.AssertExpectations(t)
+	})
+
+	t.Run("should not follow a user who blocked you", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2}, nil)
+		mockFollowerStore.On("FollowUser", mock.Anything, int64(1), int64(2)).Return(store.ErrorBlocked).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/2/follow", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockFollowerStore.AssertExpectations(t)
+	})
+
+	t.Run("should hide posts between blocked users", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostsStore := new(store.MockPostStore)
+		mockBlockStore := new(store.MockBlockStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostsStore
+		app.store.Blocks = mockBlockStore
+		app.store.Comments = mockCommentStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockPostsStore.On("GetById", mock.Anything, int64(7)).Return(store.Post{ID: 7, UserId: 2}, nil)
+		mockBlockStore.On("IsBlocked", mock.Anything, int64(1), int64(2)).Return(true, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/posts/7", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockCommentStore.AssertNotCalled(t, "GetByPostId", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetMuted(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should list the muted users of the current user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMuteStore := new(store.MockMuteStore)
+		app.store.Users = mockUserStore
+		app.store.Mutes = mockMuteStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockMuteStore.On("GetMuted", mock.Anything, int64(1), store.PaginatedKeysetQuery{Limit: 21}).Return([]store.UserEntry{{ID: 2}}, nil).Once()
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/me/mutes", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockMuteStore.AssertExpectations(t)
+	})
+}
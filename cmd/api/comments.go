@@ -32,6 +32,8 @@ type UpdateCommentPayload struct {
 //	@Param			payload	body		CreateCommentPayload	true	"Create comment payload"
 //	@Success		201		{object}	store.Comment
 //	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //
 //	@Security		ApiKeyAuth
@@ -48,7 +50,31 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ctx := r.Context()
 	user := getUserFromContext(r)
+
+	post, err := app.store.Posts.GetById(ctx, payload.PostId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// only those who can see the post of a private account may comment on it
+	visible, err := app.canViewContentOf(ctx, user, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFoundErrorResponse(w, r, store.ErrorNotFound)
+		return
+	}
+
 	comment := &store.Comment{
 		PostId:  payload.PostId,
 		UserId:  user.ID,
@@ -56,9 +82,15 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		User:    *user,
 	}
 
-	ctx := r.Context()
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
-		app.internalServerError(w, r, err)
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		case store.ErrorBlocked:
+			app.forbiddenErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -71,11 +103,13 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 // GetComments godoc
 //
 //	@Summary		Get comments
-//	@Description	get comments for a post
+//	@Description	get a page of the comments for a post, newest first
 //	@Tags			comments
 //	@Accept			json
 //	@Produce		json
-//	@Param			postId	path		int	true	"Post ID"
+//	@Param			postId	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Comments per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
 //	@Success		200		{array}		store.Comment
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
@@ -90,13 +124,24 @@ func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), postId)
+	q, err := parseKeysetQuery(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	// one extra row tells whether there is another page
+	limit := q.Limit
+	q.Limit++
+	comments, err := app.store.Comments.ListByPostId(r.Context(), postId, getUserFromContext(r).ID, q)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, comments); err != nil {
+	comments, page := paginate(comments, limit, q.Cursor, false)
+
+	if err := app.pagedJSONResponse(w, r, http.StatusOK, comments, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
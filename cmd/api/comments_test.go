@@ -34,7 +34,14 @@ func TestCreateComment(t *testing.T) {
 			Email:    "test@test.com",
 		}
 
+		mockPostStore := new(store.MockPostStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		app.store.Posts = mockPostStore
+		app.store.Comments = mockCommentStore
+
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil).Once()
+		mockPostStore.On("GetById", mock.Anything, int64(1)).Return(store.Post{ID: 1, UserId: 1}, nil).Once()
+		mockCommentStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
 
 		createComment := CreateCommentPayload{
 			PostId:  1,
@@ -56,6 +63,30 @@ func TestCreateComment(t *testing.T) {
 		t.Logf("response: %s", rr.Body.String())
 
 		checkResponseCode(t, http.StatusCreated, rr.Code)
+		mockCommentStore.AssertExpectations(t)
+	})
+
+	t.Run("should not comment on the posts of a private account", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostStore := new(store.MockPostStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostStore
+		app.store.Followers = mockFollowerStore
+		app.store.Comments = mockCommentStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, IsPrivate: true}, nil)
+		mockPostStore.On("GetById", mock.Anything, int64(7)).Return(store.Post{ID: 7, UserId: 2}, nil).Once()
+		mockFollowerStore.On("IsFollowing", mock.Anything, int64(1), int64(2)).Return(false, nil).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/posts/7/comments", testToken, CreateCommentPayload{PostId: 7, Content: "test"})
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockCommentStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
 	})
 
 	t.Run("should not allow unauthenticated requests", func(t *testing.T) {
@@ -108,7 +139,7 @@ func TestGetComments(t *testing.T) {
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil).Once()
 
-		mockCommentStore.On("GetByPostId", mock.Anything, int64(1)).Return(
+		mockCommentStore.On("ListByPostId", mock.Anything, int64(1), int64(1), store.PaginatedKeysetQuery{Limit: 21}).Return(
 			[]store.Comment{{Id: 1, PostId: 1, Content: "test"}},
 			nil).Once()
 
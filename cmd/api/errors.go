@@ -1,7 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+)
+
+// Error codes sent with errors clients have to handle differently from a
+// plain 403.
+const (
+	errCodeAccountSuspended = "account_suspended"
+	errCodeAccountBanned    = "account_banned"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -40,6 +51,18 @@ func (app *application) forbiddenErrorResponse(w http.ResponseWriter, r *http.Re
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+func (app *application) accountSuspendedResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	app.logger.Warnw("suspended account", "method", r.Method, "path", r.URL.Path, "user_id", user.ID)
+
+	writeJSONErrorCode(w, http.StatusForbidden, errCodeAccountSuspended, fmt.Sprintf("account suspended until %s: %s", user.SuspendedUntil.UTC().Format(time.RFC3339), user.SuspensionReason))
+}
+
+func (app *application) accountBannedResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	app.logger.Warnw("banned account", "method", r.Method, "path", r.URL.Path, "user_id", user.ID)
+
+	writeJSONErrorCode(w, http.StatusForbidden, errCodeAccountBanned, "account banned: "+user.BanReason)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
@@ -47,3 +70,11 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("login locked", "method", r.Method, "path", r.URL.Path, "retry_after", retryAfter.String())
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())))
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter.Round(time.Second).String())
+}
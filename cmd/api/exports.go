@@ -0,0 +1,347 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	exportPollInterval = 10 * time.Second
+	// exportPageSize is how many followers are read at a time while an
+	// export is built.
+	exportPageSize = 100
+)
+
+// ExportView is an export with the link to download it once it is ready.
+type ExportView struct {
+	store.Export
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// RequestExport godoc
+//
+//	@Summary		Request data export
+//	@Description	queue a copy of the current user's profile, posts, comments, follows and sessions. A download link is emailed once the archive is ready
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	ExportView
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/exports [post]
+func (app *application) requestExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	export := &store.Export{
+		ID:     uuid.New().String(),
+		UserId: user.ID,
+	}
+
+	if err := app.store.Exports.Create(r.Context(), export); err != nil {
+		switch err {
+		case store.ErrorAlreadyExists:
+			app.conflictErrorResponse(w, r, errors.New("an export is already in progress"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("data export requested", "user_id", user.ID, "export_id", export.ID)
+
+	if err := app.jsonResponse(w, http.StatusAccepted, ExportView{Export: *export}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetExports godoc
+//
+//	@Summary		List data exports
+//	@Description	list the data exports of the current user, newest first. Ready exports carry their download link
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		ExportView
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/exports [get]
+func (app *application) getExportsHandler(w http.ResponseWriter, r *http.Request) {
+	exports, err := app.store.Exports.GetByUser(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	views := make([]ExportView, 0, len(exports))
+	for _, export := range exports {
+		view := ExportView{Export: export}
+		if export.Status == store.ExportReady && export.ExpiresAt != nil && export.ExpiresAt.After(time.Now()) {
+			view.DownloadURL = app.exportDownloadURL(export.ID, *export.ExpiresAt)
+		}
+		views = append(views, view)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, views); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DownloadExport godoc
+//
+//	@Summary		Download data export
+//	@Description	download the archive of a data export through the signed link sent by email
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportId	path	string	true	"Export ID"
+//	@Param			expires		query	int		true	"Expiry of the link as a Unix time"
+//	@Param			signature	query	string	true	"Signature of the link"
+//	@Success		200
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Router			/exports/{exportId}/download [get]
+func (app *application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportId := chi.URLParam(r, "exportId")
+	query := r.URL.Query()
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !app.validExportSignature(exportId, expires, query.Get("signature")) {
+		app.forbiddenErrorResponse(w, r, errors.New("invalid download link"))
+		return
+	}
+
+	if time.Now().Unix() >= expires {
+		app.forbiddenErrorResponse(w, r, errors.New("download link has expired"))
+		return
+	}
+
+	export, err := app.store.Exports.GetArchive(r.Context(), exportId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="goblog-export-%s.zip"`, export.CreatedAt.UTC().Format("2006-01-02")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Archive)
+}
+
+// exportDownloadURL signs a link to the archive that stops working when the
+// archive expires, so it can be opened without logging in.
+func (app *application) exportDownloadURL(exportId string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("%s/v1/exports/%s/download?expires=%d&signature=%s", app.config.apiUrl, exportId, expires, app.exportSignature(exportId, expires))
+}
+
+func (app *application) exportSignature(exportId string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(app.config.exports.secret))
+	fmt.Fprintf(mac, "%s.%d", exportId, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomSecret returns a key to sign export links with when none is
+// configured.
+func randomSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func (app *application) validExportSignature(exportId string, expires int64, signature string) bool {
+	expected := app.exportSignature(exportId, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// runExportWorker builds the queued exports and deletes the expired ones,
+// until ctx is cancelled.
+func (app *application) runExportWorker(ctx context.Context) {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		app.processPendingExports(ctx)
+
+		deleted, err := app.store.Exports.DeleteExpired(ctx)
+		if err != nil {
+			app.logger.Errorw("failed to delete expired exports", "error", err.Error())
+		} else if deleted > 0 {
+			app.logger.Infow("deleted expired exports", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) processPendingExports(ctx context.Context) {
+	for ctx.Err() == nil {
+		export, err := app.store.Exports.Claim(ctx)
+		if err != nil {
+			if !errors.Is(err, store.ErrorNotFound) {
+				app.logger.Errorw("failed to claim export", "error", err.Error())
+			}
+			return
+		}
+
+		if err := app.processExport(ctx, export); err != nil {
+			app.logger.Errorw("failed to build export", "export_id", export.ID, "user_id", export.UserId, "error", err.Error())
+		}
+	}
+}
+
+// processExport builds the archive of a claimed export and emails its link.
+// An export that cannot be built is marked as failed.
+func (app *application) processExport(ctx context.Context, export *store.Export) error {
+	user, err := app.store.Users.GetById(ctx, export.UserId)
+	if err != nil {
+		return errors.Join(err, app.store.Exports.Fail(ctx, export.ID))
+	}
+
+	archive, err := app.buildExportArchive(ctx, user)
+	if err != nil {
+		return errors.Join(err, app.store.Exports.Fail(ctx, export.ID))
+	}
+
+	export.Archive = archive
+	if err := app.store.Exports.Complete(ctx, export, app.config.exports.expiry); err != nil {
+		return err
+	}
+
+	app.logger.Infow("data export ready", "export_id", export.ID, "user_id", user.ID, "size", len(archive))
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username    string
+		DownloadURL string
+		ExpiresIn   string
+	}{
+		Username:    user.Username,
+		DownloadURL: app.exportDownloadURL(export.ID, *export.ExpiresAt),
+		ExpiresIn:   app.config.exports.expiry.String(),
+	}
+
+	// the link is also listed with the exports, so a lost email is not fatal
+	if err := app.mailer.Send(mailer.DataExport, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		app.logger.Errorw("failed to send data export email", "export_id", export.ID, "user_id", user.ID, "error", err.Error())
+	}
+
+	return nil
+}
+
+// buildExportArchive zips one JSON file per kind of data the user owns. It
+// reads everything through the store, so it works the same with the mocks.
+func (app *application) buildExportArchive(ctx context.Context, user *store.User) ([]byte, error) {
+	posts, err := app.store.Posts.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := app.store.Comments.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	followers, err := collectFollows(ctx, user.ID, app.store.Followers.GetFollowers)
+	if err != nil {
+		return nil, err
+	}
+
+	following, err := collectFollows(ctx, user.ID, app.store.Followers.GetFollowing)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := app.store.Sessions.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"followers.json", followers},
+		{"following.json", following},
+		{"sessions.json", sessions},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func collectFollows(ctx context.Context, userId int64, list func(ctx context.Context, userId int64, q store.PaginatedKeysetQuery) ([]store.FollowEntry, error)) ([]store.FollowEntry, error) {
+	all := []store.FollowEntry{}
+	q := store.PaginatedKeysetQuery{Limit: exportPageSize}
+
+	for {
+		entries, err := list(ctx, userId, q)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+
+		if len(entries) < q.Limit {
+			return all, nil
+		}
+
+		cursor := entries[len(entries)-1].Cursor()
+		q.Cursor = &cursor
+	}
+}
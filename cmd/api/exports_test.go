@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRequestExport(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should queue one export at a time", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockExportStore := new(store.MockExportStore)
+		app.store.Users = mockUserStore
+		app.store.Exports = mockExportStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockExportStore.On("Create", mock.Anything, mock.MatchedBy(func(e *store.Export) bool { return e.UserId == 1 })).Return(nil).Once()
+		mockExportStore.On("Create", mock.Anything, mock.Anything).Return(store.ErrorAlreadyExists).Once()
+
+		for _, expected := range []int{http.StatusAccepted, http.StatusConflict} {
+			req := newAuthedRequest(t, http.MethodPost, "/v1/users/me/exports", testToken, nil)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, expected, rr.Code)
+		}
+		mockExportStore.AssertExpectations(t)
+	})
+}
+
+func TestProcessExport(t *testing.T) {
+	app := newTestApplication(t, config{exports: exportConfig{secret: "test", expiry: time.Hour}})
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*store.MockExportStore, *mailer.MockMailer) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostStore := new(store.MockPostStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		mockSessionStore := new(store.MockSessionStore)
+		mockExportStore := new(store.MockExportStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostStore
+		app.store.Comments = mockCommentStore
+		app.store.Followers = mockFollowerStore
+		app.store.Sessions = mockSessionStore
+		app.store.Exports = mockExportStore
+		app.mailer = mockMailer
+
+		// a full first page makes the export ask for the next one
+		followers := make([]store.FollowEntry, exportPageSize)
+		for i := range followers {
+			followers[i] = store.FollowEntry{ID: int64(i + 2), FollowedAt: time.Now()}
+		}
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Username: "alice", Email: "alice@example.com"}, nil)
+		mockPostStore.On("GetByUser", mock.Anything, int64(1)).Return([]store.Post{{ID: 7, UserId: 1, Title: "hello"}}, nil)
+		mockCommentStore.On("GetByUser", mock.Anything, int64(1)).Return([]store.Comment{{Id: 3, PostId: 7, UserId: 1}}, nil)
+		mockFollowerStore.On("GetFollowers", mock.Anything, int64(1), mock.MatchedBy(func(q store.PaginatedKeysetQuery) bool { return q.Cursor == nil })).Return(followers, nil).Once()
+		mockFollowerStore.On("GetFollowers", mock.Anything, int64(1), mock.MatchedBy(func(q store.PaginatedKeysetQuery) bool { return q.Cursor != nil })).Return([]store.FollowEntry{{ID: 500}}, nil).Once()
+		mockFollowerStore.On("GetFollowing", mock.Anything, int64(1), mock.Anything).Return([]store.FollowEntry{}, nil)
+		mockSessionStore.On("GetByUser", mock.Anything, int64(1)).Return([]store.Session{{ID: "s1", UserId: 1}}, nil)
+		return mockExportStore, mockMailer
+	}
+
+	t.Run("should archive the user's data and email the link", func(t *testing.T) {
+		mockExportStore, mockMailer := setup(t)
+		expiresAt := time.Now().Add(time.Hour)
+		mockExportStore.On("Complete", mock.Anything, mock.Anything, time.Hour).Run(func(args mock.Arguments) {
+			args.Get(1).(*store.Export).ExpiresAt = &expiresAt
+		}).Return(nil).Once()
+		mockMailer.On("Send", mailer.DataExport, "alice", "alice@example.com", mock.Anything, true).Return(nil).Once()
+
+		export := &store.Export{ID: "e1", UserId: 1}
+		if err := app.processExport(ctx, export); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(export.Archive), int64(len(export.Archive)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string]*zip.File{}
+		for _, f := range zr.File {
+			files[f.Name] = f
+		}
+		for _, name := range []string{"profile.json", "posts.json", "comments.json", "followers.json", "following.json", "sessions.json"} {
+			if files[name] == nil {
+				t.Errorf("expected %s in the archive", name)
+			}
+		}
+
+		rc, err := files["followers.json"].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+
+		var followers []store.FollowEntry
+		if err := json.NewDecoder(rc).Decode(&followers); err != nil {
+			t.Fatal(err)
+		}
+		if len(followers) != exportPageSize+1 {
+			t.Errorf("expected %d followers, got %d", exportPageSize+1, len(followers))
+		}
+
+		mockExportStore.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should mark the export as failed when the data cannot be read", func(t *testing.T) {
+		mockExportStore, mockMailer := setup(t)
+		app.store.Sessions = new(store.MockSessionStore)
+		app.store.Sessions.(*store.MockSessionStore).On("GetByUser", mock.Anything, int64(1)).Return([]store.Session(nil), errors.New("connection reset"))
+		mockExportStore.On("Fail", mock.Anything, "e1").Return(nil).Once()
+
+		if err := app.processExport(ctx, &store.Export{ID: "e1", UserId: 1}); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		mockExportStore.AssertExpectations(t)
+		mockExportStore.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything)
+		mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDownloadExport(t *testing.T) {
+	app := newTestApplication(t, config{exports: exportConfig{secret: "test", expiry: time.Hour}})
+	mux := app.mount()
+
+	archive := []byte("zip")
+
+	setup := func(t *testing.T) *store.MockExportStore {
+		mockExportStore := new(store.MockExportStore)
+		app.store.Exports = mockExportStore
+		mockExportStore.On("GetArchive", mock.Anything, "e1").Return(&store.Export{ID: "e1", Archive: archive}, nil)
+		return mockExportStore
+	}
+
+	download := func(t *testing.T, url string) *httptest.ResponseRecorder {
+		return executeRequest(newJSONRequest(t, http.MethodGet, strings.TrimPrefix(url, app.config.apiUrl), nil), mux)
+	}
+
+	t.Run("should serve the archive through a signed link", func(t *testing.T) {
+		setup(t)
+		rr := download(t, app.exportDownloadURL("e1", time.Now().Add(time.Hour)))
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if !bytes.Equal(rr.Body.Bytes(), archive) {
+			t.Errorf("expected the archive in the body")
+		}
+	})
+
+	t.Run("should reject tampered and expired links", func(t *testing.T) {
+		mockExportStore := setup(t)
+
+		tampered := strings.Replace(app.exportDownloadURL("e1", time.Now().Add(time.Hour)), "/e1/", "/e2/", 1)
+		checkResponseCode(t, http.StatusForbidden, download(t, tampered).Code)
+
+		expired := app.exportDownloadURL("e1", time.Now().Add(-time.Minute))
+		checkResponseCode(t, http.StatusForbidden, download(t, expired).Code)
+
+		mockExportStore.AssertNotCalled(t, "GetArchive", mock.Anything, mock.Anything)
+	})
+}
@@ -4,28 +4,58 @@ import (
 	"fmt"
 	"net/http"
 	"social/internal/store"
+	"time"
 )
 
 // getUserFeedHandler retrieves a paginated list of user feed posts.
 //
 //	@Summary		Get user feed
-//	@Description	Retrieves a paginated list of posts in the user's feed.
+//	@Description	Retrieves a paginated list of posts by the authors the user follows and by the user.
 //	@Tags			Feed
 //	@Accept			json
 //	@Produce		json
 //	@Param			limit	query		int						false	"Limit of posts per page"				default(10)
-//	@Param			offset	query		int						false	"Offset for pagination"					default(0)
-//	@Param			sort	query		string					false	"Sort order, either 'asc' or 'desc'"	default(desc)
+//	@Param			offset	query		int						false	"Offset for pagination, instead of a cursor"	default(0)
+//	@Param			cursor	query		string					false	"next_cursor or prev_cursor of another page"
+//	@Param			sort	query		string					false	"Sort order, either 'asc', 'desc' or 'top' to rank by comments, reactions, affinity to the author and age"	default(desc)
 //	@Param			since	query		string					false	"Filter posts created after this date (format: YYYY-MM-DDTHH:MM:SSZ)"
 //	@Param			until	query		string					false	"Filter posts created before this date (format: YYYY-MM-DDTHH:MM:SSZ)"
 //	@Param			search	query		string					false	"Search term to filter posts by title or content"
 //	@Param			tags	query		string					false	"Comma-separated list of tags to filter posts"
-//	@Success		200		{array}		store.PostWithMetadata	"List of posts with metadata"
+//	@Success		200		{array}		store.PostWithMetadata	"List of posts with metadata, with next_cursor and prev_cursor next to the data"
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeFeed(w, r, app.getHomeFeed)
+}
+
+// getExploreFeedHandler retrieves a paginated list of posts by everyone.
+//
+//	@Summary		Get explore feed
+//	@Description	Retrieves a paginated list of posts by everyone but the user, including authors the user does not follow.
+//	@Tags			Feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int						false	"Limit of posts per page"				default(10)
+//	@Param			offset	query		int						false	"Offset for pagination, instead of a cursor"	default(0)
+//	@Param			cursor	query		string					false	"next_cursor or prev_cursor of another page"
+//	@Param			sort	query		string					false	"Sort order, either 'asc', 'desc' or 'top' to rank by comments, reactions, affinity to the author and age"	default(desc)
+//	@Param			since	query		string					false	"Filter posts created after this date (format: YYYY-MM-DDTHH:MM:SSZ)"
+//	@Param			until	query		string					false	"Filter posts created before this date (format: YYYY-MM-DDTHH:MM:SSZ)"
+//	@Param			search	query		string					false	"Search term to filter posts by title or content"
+//	@Param			tags	query		string					false	"Comma-separated list of tags to filter posts"
+//	@Success		200		{array}		store.PostWithMetadata	"List of posts with metadata, with next_cursor and prev_cursor next to the data"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/explore [get]
+func (app *application) getExploreFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeFeed(w, r, app.store.Posts.GetExploreFeed)
+}
+
+func (app *application) writeFeed(w http.ResponseWriter, r *http.Request, list feedLister) {
 	fq := store.PaginatedFeedQuery{
 		Limit:  20,
 		Offset: 0,
@@ -48,13 +78,36 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 	user := getUserFromContext(r)
-	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
+
+	// scores move with time, so ranked pages are only read by offset and
+	// have no cursors
+	if fq.Sort == store.SortTop {
+		feed, err := app.rankFeed(ctx, user.ID, fq, list, time.Now())
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err = app.pagedJSONResponse(w, r, http.StatusOK, feed, Page{}); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		return
+	}
+
+	// one extra row tells whether there is another page
+	limit := fq.Limit
+	fq.Limit++
+
+	feed, err := list(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	feed, page := paginate(feed, limit, fq.Cursor, fq.Offset > 0)
+
+	if err = app.pagedJSONResponse(w, r, http.StatusOK, feed, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
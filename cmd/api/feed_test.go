@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net/http"
 	"social/internal/store"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -116,4 +118,145 @@ func TestFeed(t *testing.T) {
 		}
 	})
 
+	t.Run("should return explore feed with the feed filters", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostsStore := new(store.MockPostStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostsStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil).Once()
+		mockPostsStore.On("GetExploreFeed", mock.Anything, int64(1), store.PaginatedFeedQuery{
+			Limit:  6,
+			Sort:   "desc",
+			Tags:   []string{"go", "sql"},
+			Search: "index",
+		}).Return([]store.PostWithMetadata{{Post: store.Post{ID: 3, UserId: 2}}}, nil).Once()
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/explore?limit=5&tags=go,sql&search=index", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockPostsStore.AssertExpectations(t)
+		mockPostsStore.AssertNotCalled(t, "GetUserFeed", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestFeedPagination(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(id int64, createdAt string) store.PostWithMetadata {
+		return store.PostWithMetadata{Post: store.Post{ID: id, CreatedAt: createdAt}}
+	}
+	posts := []store.PostWithMetadata{
+		post(3, "2025-01-03T10:00:00.5Z"),
+		post(2, "2025-01-02T10:00:00Z"),
+		post(1, "2025-01-01T10:00:00Z"),
+	}
+
+	type feedPage struct {
+		Data       []store.PostWithMetadata `json:"data"`
+		NextCursor string                   `json:"next_cursor"`
+		PrevCursor string                   `json:"prev_cursor"`
+	}
+
+	getFeed := func(t *testing.T, url string) (*feedPage, http.Header) {
+		req := newAuthedRequest(t, http.MethodGet, url, testToken, nil)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var page feedPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		return &page, rr.Header()
+	}
+
+	setup := func(t *testing.T) *store.MockPostStore {
+		mockUserStore := new(store.MockUserStore)
+		mockPostsStore := new(store.MockPostStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostsStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		return mockPostsStore
+	}
+
+	t.Run("should return the cursor of the next page", func(t *testing.T) {
+		mockPostsStore := setup(t)
+		mockPostsStore.On("GetUserFeed", mock.Anything, int64(1), mock.MatchedBy(func(fq store.PaginatedFeedQuery) bool {
+			return fq.Limit == 3 && fq.Cursor == nil
+		})).Return(posts, nil).Once()
+
+		page, header := getFeed(t, "/v1/users/feed?limit=2&tags=go")
+
+		if len(page.Data) != 2 || page.Data[1].ID != 2 {
+			t.Fatalf("expected the first two posts, got %+v", page.Data)
+		}
+		if page.PrevCursor != "" {
+			t.Errorf("expected no previous page, got %q", page.PrevCursor)
+		}
+
+		cursor, err := store.DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cursor.ID != 2 || cursor.Before {
+			t.Errorf("expected the next page to start after post 2, got %+v", cursor)
+		}
+
+		link := header.Get("Link")
+		if !strings.Contains(link, "cursor="+page.NextCursor) || !strings.Contains(link, "tags=go") || !strings.Contains(link, `rel="next"`) {
+			t.Errorf("unexpected Link header %q", link)
+		}
+	})
+
+	t.Run("should page back from a cursor", func(t *testing.T) {
+		mockPostsStore := setup(t)
+		before := store.Cursor{CreatedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), ID: 1, Before: true}
+		mockPostsStore.On("GetUserFeed", mock.Anything, int64(1), mock.MatchedBy(func(fq store.PaginatedFeedQuery) bool {
+			return fq.Cursor != nil && *fq.Cursor == before
+		})).Return(posts, nil).Once()
+
+		page, _ := getFeed(t, "/v1/users/feed?limit=2&cursor="+before.Encode())
+
+		if len(page.Data) != 2 || page.Data[0].ID != 2 {
+			t.Fatalf("expected the two posts before the cursor, got %+v", page.Data)
+		}
+		if page.PrevCursor == "" || page.NextCursor == "" {
+			t.Errorf("expected cursors on both sides, got %+v", page)
+		}
+	})
+
+	t.Run("should keep the offset mode", func(t *testing.T) {
+		mockPostsStore := setup(t)
+		mockPostsStore.On("GetUserFeed", mock.Anything, int64(1), mock.MatchedBy(func(fq store.PaginatedFeedQuery) bool {
+			return fq.Offset == 2 && fq.Cursor == nil
+		})).Return(posts[2:], nil).Once()
+
+		page, _ := getFeed(t, "/v1/users/feed?limit=2&offset=2")
+
+		if len(page.Data) != 1 || page.NextCursor != "" || page.PrevCursor == "" {
+			t.Errorf("expected the last page, got %+v", page)
+		}
+	})
+
+	t.Run("should reject a cursor combined with an offset", func(t *testing.T) {
+		mockPostsStore := setup(t)
+		cursor := store.Cursor{CreatedAt: time.Now(), ID: 1}.Encode()
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/feed?offset=10&cursor="+cursor, testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockPostsStore.AssertNotCalled(t, "GetUserFeed", mock.Anything, mock.Anything, mock.Anything)
+	})
 }
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const followRequestedMessage = "the account is private, a follow request has been sent"
+
+// GetFollowers godoc
+//
+//	@Summary		List followers
+//	@Description	list the users following a user, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.FollowEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeUserFollowList(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		List followed users
+//	@Description	list the users a user follows, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.FollowEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeUserFollowList(w, r, app.store.Followers.GetFollowing)
+}
+
+type followListFunc func(ctx context.Context, userId int64, q store.PaginatedKeysetQuery) ([]store.FollowEntry, error)
+
+func (app *application) writeUserFollowList(w http.ResponseWriter, r *http.Request, list followListFunc) {
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	q, err := parseKeysetQuery(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.getUserWithRedis(r.Context(), userId); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeFollowList(w, r, userId, q, list)
+}
+
+func (app *application) writeFollowList(w http.ResponseWriter, r *http.Request, userId int64, q store.PaginatedKeysetQuery, list followListFunc) {
+	// one extra row tells whether there is another page
+	limit := q.Limit
+	q.Limit++
+	entries, err := list(r.Context(), userId, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	users, page := paginate(entries, limit, q.Cursor, false)
+
+	if err := app.pagedJSONResponse(w, r, http.StatusOK, users, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// IsFollowing godoc
+//
+//	@Summary		Check follow
+//	@Description	check whether a user follows another user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId		path	int	true	"ID of the follower"
+//	@Param			targetId	path	int	true	"ID of the followed user"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/following/{targetId} [get]
+func (app *application) isFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	targetId, err := strconv.ParseInt(chi.URLParam(r, "targetId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	following, err := app.store.Followers.IsFollowing(r.Context(), userId, targetId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !following {
+		app.notFoundErrorResponse(w, r, errors.New("user does not follow the target"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRelationship godoc
+//
+//	@Summary		Get relationship
+//	@Description	get whether the current user and a user follow each other
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		200		{object}	store.Relationship
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/relationship [get]
+func (app *application) getRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	rel, err := app.store.Followers.GetRelationship(r.Context(), getUserFromContext(r).ID, userId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, rel); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, requesterId, userId int64) {
+	if err := app.store.Followers.RequestFollow(r.Context(), requesterId, userId); err != nil {
+		switch err {
+		case store.ErrorAlreadyExists:
+			app.conflictErrorResponse(w, r, errors.New("already following or requested"))
+		case store.ErrorBlocked:
+			app.forbiddenErrorResponse(w, r, err)
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, followRequestedMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		List follow requests
+//	@Description	list the pending requests to follow the current user, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.FollowEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseKeysetQuery(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeFollowList(w, r, getUserFromContext(r).ID, q, app.store.Followers.GetFollowRequests)
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approve follow request
+//	@Description	let the requesting user follow the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"ID of the requesting user"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userId} [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, func(ctx context.Context, userId, requesterId int64) error {
+		if err := app.store.Followers.ApproveFollowRequest(ctx, userId, requesterId); err != nil {
+			return err
+		}
+
+		app.invalidateTimeline(ctx, requesterId)
+		return nil
+	})
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Reject follow request
+//	@Description	reject a pending request to follow the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"ID of the requesting user"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userId} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.Followers.DeleteFollowRequest)
+}
+
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, userId, requesterId int64) error) {
+	requesterId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	if err := resolve(r.Context(), getUserFromContext(r).ID, requesterId); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canViewContentOf reports whether the viewer may see posts and comments of
+// the author. Private accounts only show them to approved followers, and
+// users who blocked each other see nothing of one another.
+func (app *application) canViewContentOf(ctx context.Context, viewer *store.User, authorId int64) (bool, error) {
+	if viewer.ID == authorId {
+		return true, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, authorId)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	author, err := app.getUserWithRedis(ctx, authorId)
+	if err != nil {
+		return false, err
+	}
+
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	return app.store.Followers.IsFollowing(ctx, viewer.ID, authorId)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFollowUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow following yourself", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/1/follow", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockFollowerStore.AssertNotCalled(t, "FollowUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return not found for a nonexistent user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(99)).Return(nil, store.ErrorNotFound)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/99/follow", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockFollowerStore.AssertNotCalled(t, "FollowUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should request to follow a private account", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, IsPrivate: true}, nil)
+		mockFollowerStore.On("RequestFollow", mock.Anything, int64(1), int64(2)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/2/follow", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		mockFollowerStore.AssertExpectations(t)
+		mockFollowerStore.AssertNotCalled(t, "FollowUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should approve a follow request", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, IsPrivate: true}, nil)
+		mockFollowerStore.On("ApproveFollowRequest", mock.Anything, int64(1), int64(3)).Return(nil).Once()
+		mockFollowerStore.On("ApproveFollowRequest", mock.Anything, int64(1), int64(4)).Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/me/follow-requests/3", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/me/follow-requests/4", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockFollowerStore.AssertExpectations(t)
+	})
+}
+
+func TestPrivatePost(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(t *testing.T, following bool) *store.MockCommentsStore {
+		mockUserStore := new(store.MockUserStore)
+		mockPostsStore := new(store.MockPostStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		mockCommentStore := new(store.MockCommentsStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostsStore
+		app.store.Followers = mockFollowerStore
+		app.store.Comments = mockCommentStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2, IsPrivate: true}, nil)
+		mockPostsStore.On("GetById", mock.Anything, int64(7)).Return(store.Post{ID: 7, UserId: 2}, nil)
+		mockFollowerStore.On("IsFollowing", mock.Anything, int64(1), int64(2)).Return(following, nil)
+		mockCommentStore.On("GetByPostId", mock.Anything, int64(7), int64(1)).Return([]store.Comment{}, nil)
+		return mockCommentStore
+	}
+
+	t.Run("should hide the post from users who do not follow the author", func(t *testing.T) {
+		mockCommentStore := setup(t, false)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/posts/7", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+		mockCommentStore.AssertNotCalled(t, "GetByPostId", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should show the post to approved followers", func(t *testing.T) {
+		setup(t, true)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/posts/7", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestGetFollowers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should return a cursor when there are more followers", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, mock.Anything).Return(&store.User{ID: 1}, nil)
+		mockFollowerStore.On("GetFollowers", mock.Anything, int64(2), store.PaginatedKeysetQuery{Limit: 3}).Return([]store.FollowEntry{
+			{ID: 5, FollowedAt: followedAt, Mutual: true},
+			{ID: 4, FollowedAt: followedAt},
+			{ID: 3, FollowedAt: followedAt},
+		}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/2/followers?limit=2", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data       []store.FollowEntry `json:"data"`
+			NextCursor string              `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data) != 2 {
+			t.Fatalf("expected 2 users, got %d", len(body.Data))
+		}
+
+		cursor, err := store.DecodeCursor(body.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cursor.ID != 4 || !cursor.CreatedAt.Equal(followedAt) {
+			t.Errorf("expected the cursor of the last user, got %+v", cursor)
+		}
+		mockFollowerStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject a malformed cursor", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/2/followers?cursor=not-a-cursor", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"social/internal/store"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const invitationCleanupInterval = time.Hour
+
+type ResendInvitationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+const resendInvitationMessage = "if a pending account with this email exists, a new activation link has been sent"
+
+// ResendInvitation godoc
+//
+//	@Summary		Resend invitation
+//	@Description	send a new activation link for an account that has not been activated yet. The response does not reveal whether the account exists
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendInvitationPayload	true	"Account email"
+//	@Success		202		{string}	string					"Invitation sent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Router			/authentication/invitation/resend [post]
+func (app *application) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendInvitationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if allow, retryAfter := app.invitationLimiter.Allow(strings.ToLower(payload.Email)); !allow {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	// the account is looked up after the response, which then takes as long
+	// for an email without a pending account as for one with it
+	app.runInBackground(func() {
+		if err := app.resendInvitation(context.Background(), payload.Email); err != nil {
+			app.logger.Errorw("failed to resend invitation", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, resendInvitationMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// resendInvitation replaces the invitation of the pending account with the
+// email, if there is one, and sends the new activation link.
+func (app *application) resendInvitation(ctx context.Context, email string) error {
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	user, err := app.store.Users.ReplaceInvitation(ctx, email, hashToken, app.config.mail.expiry)
+	switch err {
+	case nil:
+	case store.ErrorNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	return app.sendInvitation(user, plainToken)
+}
+
+// runInvitationCleanup periodically deletes accounts whose invitations
+// expired before they were activated, until ctx is cancelled.
+func (app *application) runInvitationCleanup(ctx context.Context) {
+	ticker := time.NewTicker(invitationCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := app.store.Users.DeleteExpiredInvitations(ctx)
+		if err != nil {
+			app.logger.Errorw("failed to delete expired invitations", "error", err.Error())
+		} else if deleted > 0 {
+			app.logger.Infow("deleted accounts with expired invitations", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/ratelimiter"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResendInvitation(t *testing.T) {
+	cfg := config{
+		redisCfg: redisConfig{
+			enabled: false,
+		},
+		invitationRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 2,
+			TimeFrame:            time.Hour,
+		},
+	}
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	t.Run("should send a new invitation", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		user := &store.User{ID: 1, Username: "test", Email: "test@test.com"}
+		mockUserStore.On("ReplaceInvitation", mock.Anything, "test@test.com", mock.Anything, mock.Anything).Return(user, nil).Once()
+		mockMailer.On("Send", mailer.UserWelcome, "test", "test@test.com", mock.Anything, true).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "test@test.com"}), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		app.background.Wait()
+		mockUserStore.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should answer before the invitation is sent", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		sent := make(chan time.Time)
+		user := &store.User{ID: 1, Username: "test", Email: "slow@test.com"}
+		mockUserStore.On("ReplaceInvitation", mock.Anything, "slow@test.com", mock.Anything, mock.Anything).Return(user, nil).Once()
+		mockMailer.On("Send", mailer.UserWelcome, "test", "slow@test.com", mock.Anything, true).WaitUntil(sent).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "slow@test.com"}), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		close(sent)
+		app.background.Wait()
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should not reveal that there is no pending account", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		mockUserStore.On("ReplaceInvitation", mock.Anything, "active@test.com", mock.Anything, mock.Anything).Return(nil, store.ErrorNotFound).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "active@test.com"}), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		app.background.Wait()
+		mockUserStore.AssertExpectations(t)
+		mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should rate limit resends per address", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("ReplaceInvitation", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, store.ErrorNotFound)
+
+		for i := 0; i < cfg.invitationRateLimiter.RequestsPerTimeFrame; i++ {
+			rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "limited@test.com"}), mux)
+			checkResponseCode(t, http.StatusAccepted, rr.Code)
+		}
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "Limited@test.com"}), mux)
+		checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+
+		rr = executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/invitation/resend", ResendInvitationPayload{Email: "other@test.com"}), mux)
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		app.background.Wait()
+	})
+}
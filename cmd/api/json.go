@@ -29,10 +29,17 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 }
 
 func writeJSONError(w http.ResponseWriter, status int, message string) error {
+	return writeJSONErrorCode(w, status, "", message)
+}
+
+// writeJSONErrorCode adds a machine-readable code next to the message so
+// clients can tell errors with the same status apart.
+func writeJSONErrorCode(w http.ResponseWriter, status int, code, message string) error {
 	type envelope struct {
 		Error string `json:"error"`
+		Code  string `json:"code,omitempty"`
 	}
-	return writeJSON(w, status, &envelope{Error: message})
+	return writeJSON(w, status, &envelope{Error: message, Code: code})
 }
 
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
@@ -42,3 +49,16 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+// pagedJSONResponse writes a page of a list. The cursors of the neighbouring
+// pages go next to the data and into the Link header.
+func (app *application) pagedJSONResponse(w http.ResponseWriter, r *http.Request, status int, data any, page Page) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	app.setLinkHeader(w, r, page)
+	return writeJSON(w, status, &envelope{Data: data, NextCursor: page.Next, PrevCursor: page.Prev})
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	public keys that can be used to verify access tokens
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	auth.JSONWebKeySet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := writeJSON(w, http.StatusOK, app.authenticator.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"social/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePrivateKey(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWKS(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPath := writePrivateKey(t, edKey)
+	rsaPath := writePrivateKey(t, rsaKey)
+
+	claims := jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "test",
+		"aud": "test",
+	}
+
+	oldKey, err := auth.LoadSigningKey("old", rsaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldAuthenticator := auth.NewJWTKeySetAuthenticator(oldKey, nil, "test", "test")
+
+	newKey, err := auth.LoadSigningKey("", edPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationKeys, err := auth.LoadVerificationKeys("old=" + rsaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := auth.NewJWTKeySetAuthenticator(newKey, verificationKeys, "test", "test")
+
+	app := newTestApplication(t, config{})
+	app.authenticator = authenticator
+	mux := app.mount()
+
+	t.Run("should publish every active public key", func(t *testing.T) {
+		rr := executeRequest(newJSONRequest(t, http.MethodGet, "/.well-known/jwks.json", nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var set auth.JSONWebKeySet
+		if err := json.Unmarshal(rr.Body.Bytes(), &set); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(set.Keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(set.Keys))
+		}
+		if set.Keys[0].Kid != newKey.ID || set.Keys[0].Kty != "OKP" || set.Keys[0].Alg != "EdDSA" {
+			t.Errorf("unexpected signing key %+v", set.Keys[0])
+		}
+		if set.Keys[1].Kid != "old" || set.Keys[1].Kty != "RSA" || set.Keys[1].Alg != "RS256" {
+			t.Errorf("unexpected verification key %+v", set.Keys[1])
+		}
+	})
+
+	t.Run("should accept tokens signed by a rotated key", func(t *testing.T) {
+		token, err := oldAuthenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := authenticator.ValidateToken(token); err != nil {
+			t.Errorf("expected token signed by the old key to be valid: %v", err)
+		}
+	})
+
+	t.Run("should sign tokens with the kid of the active key", func(t *testing.T) {
+		token, err := authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := authenticator.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["kid"] != newKey.ID {
+			t.Errorf("expected kid %q, got %v", newKey.ID, parsed.Header["kid"])
+		}
+
+		if _, err := oldAuthenticator.ValidateToken(token); err == nil {
+			t.Error("expected token signed by an unknown key to be rejected")
+		}
+	})
+
+	t.Run("should reject tokens signed with the shared secret", func(t *testing.T) {
+		token, err := auth.NewJWTAuthenticator("example", "test", "test").GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := authenticator.ValidateToken(token); err == nil {
+			t.Error("expected HS256 token to be rejected")
+		}
+	})
+}
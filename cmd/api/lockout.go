@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func accountLockoutKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipLockoutKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// loginLockedFor returns how long logins for the email from the client's
+// address stay locked, or 0.
+func (app *application) loginLockedFor(ctx context.Context, r *http.Request, email string) (time.Duration, error) {
+	accountLock, err := app.accountLockout.Locked(ctx, accountLockoutKey(email))
+	if err != nil {
+		return 0, err
+	}
+
+	ipLock, err := app.ipLockout.Locked(ctx, ipLockoutKey(r))
+	if err != nil {
+		return 0, err
+	}
+
+	return max(accountLock, ipLock), nil
+}
+
+// recordLoginFailure counts a failed login for the account and the client's
+// address. Unknown emails are counted too, so that locking does not reveal
+// which accounts exist. The owner is notified when the account gets locked,
+// after the response so that it takes no longer than for an unknown email.
+func (app *application) recordLoginFailure(ctx context.Context, r *http.Request, email string, user *store.User) {
+	if _, err := app.ipLockout.Fail(ctx, ipLockoutKey(r)); err != nil {
+		app.logger.Errorw("failed to record login failure", "error", err.Error())
+	}
+
+	lockedFor, err := app.accountLockout.Fail(ctx, accountLockoutKey(email))
+	if err != nil {
+		app.logger.Errorw("failed to record login failure", "error", err.Error())
+		return
+	}
+
+	if lockedFor == 0 || user == nil {
+		return
+	}
+
+	app.logger.Warnw("account locked", "user_id", user.ID, "locked_for", lockedFor.String())
+	app.runInBackground(func() {
+		if err := app.sendAccountLocked(user, lockedFor); err != nil {
+			app.logger.Errorw("failed to send account locked email", "user_id", user.ID, "error", err.Error())
+		}
+	})
+}
+
+func (app *application) resetLoginFailures(ctx context.Context, email string) {
+	if err := app.accountLockout.Reset(ctx, accountLockoutKey(email)); err != nil {
+		app.logger.Errorw("failed to reset login failures", "error", err.Error())
+	}
+}
+
+func (app *application) sendAccountLocked(user *store.User, lockedFor time.Duration) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		LockedFor string
+		ResetURL  string
+	}{
+		Username:  user.Username,
+		LockedFor: lockedFor.String(),
+		ResetURL:  fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	return app.mailer.Send(
+		mailer.AccountLocked,
+		user.Username,
+		user.Email,
+		vars,
+		!isProdEnv,
+	)
+}
+
+// UnlockUser godoc
+//
+//	@Summary		Unlock user
+//	@Description	lift the login lockout of an account
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userId}/unlock [post]
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid user ID"))
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetById(ctx, userId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.accountLockout.Reset(ctx, accountLockoutKey(user.Email)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("account unlocked", "user_id", user.ID, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
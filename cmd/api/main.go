@@ -4,10 +4,12 @@ import (
 	"context"
 	"expvar"
 	"log"
+	"net/http"
 	"runtime"
 	"social/internal/auth"
 	"social/internal/db"
 	"social/internal/env"
+	"social/internal/lockout"
 	"social/internal/ratelimiter"
 	"social/internal/store"
 	"social/internal/store/cache"
@@ -63,8 +65,10 @@ func main() {
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
-			fromEmail: env.GetString("FROM_EMAIL", ""),
-			expiry:    time.Hour * 24 * 3,
+			fromEmail:           env.GetString("FROM_EMAIL", ""),
+			expiry:              time.Hour * 24 * 3,
+			passwordResetExpiry: time.Hour,
+			emailChangeExpiry:   time.Hour * 24,
 			sendGrid: sendgridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
@@ -75,22 +79,95 @@ func main() {
 				password: env.GetString("BASIC_AUTH_PASSWORD", ""),
 			},
 			token: tokenConfig{
-				secret: env.GetString("JWT_SECRET", "example"),
-				exp:    time.Hour * 24 * 3,
-				iss:    "GoBlog",
-				aud:    "GoBlog",
+				secret:           env.GetString("JWT_SECRET", "example"),
+				signingKeyFile:   env.GetString("JWT_SIGNING_KEY_FILE", ""),
+				signingKeyID:     env.GetString("JWT_SIGNING_KEY_ID", ""),
+				verificationKeys: env.GetString("JWT_VERIFICATION_KEYS", ""),
+				exp:              time.Minute * 15,
+				refreshExp:       time.Hour * 24 * 30,
+				iss:              "GoBlog",
+				aud:              "GoBlog",
 			},
+			twoFactor: twoFactorConfig{
+				issuer:       "GoBlog",
+				challengeExp: time.Minute * 5,
+				secretKey:    env.GetString("TWO_FACTOR_SECRET_KEY", ""),
+			},
+			oauth: oauthProviderConfigs(),
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestsPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		invitationRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: env.GetInt("INVITATION_RESEND_LIMIT", 3),
+			TimeFrame:            time.Hour,
+			Enabled:              true,
+		},
+		loginLockout: loginLockoutConfig{
+			account: lockout.Config{
+				Threshold:    env.GetInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+				BaseDuration: time.Minute,
+				MaxDuration:  time.Hour * 24,
+				Window:       time.Hour,
+			},
+			ip: lockout.Config{
+				Threshold:    env.GetInt("LOGIN_LOCKOUT_IP_THRESHOLD", 20),
+				BaseDuration: time.Minute,
+				MaxDuration:  time.Hour,
+				Window:       time.Hour,
+			},
+		},
+		exports: exportConfig{
+			secret: env.GetString("EXPORT_LINK_SECRET", ""),
+			expiry: time.Hour * 24 * 7,
+		},
+		timelines: timelineConfig{
+			size:               env.GetInt("TIMELINE_SIZE", 800),
+			celebrityThreshold: env.GetInt("TIMELINE_CELEBRITY_THRESHOLD", 10000),
+		},
+		ranking: rankingConfig{
+			commentWeight:  env.GetFloat("FEED_TOP_COMMENT_WEIGHT", 1),
+			reactionWeight: env.GetFloat("FEED_TOP_REACTION_WEIGHT", 0.5),
+			affinityWeight: env.GetFloat("FEED_TOP_AFFINITY_WEIGHT", 2),
+			gravity:        env.GetFloat("FEED_TOP_GRAVITY", 1.5),
+			window:         time.Hour * 24 * 7,
+			candidates:     env.GetInt("FEED_TOP_CANDIDATES", 200),
+		},
+		reactions: reactionSet(env.GetString("REACTION_EMOJI", "❤️,😂,😮,😢,😡")),
 	}
 	//Logger
 	logger := zap.Must(zap.NewProduction(zap.AddStacktrace(zap.FatalLevel + 1))).Sugar()
 	defer logger.Sync()
 
+	trustedProxies, err := parseTrustedProxies(env.GetString("TRUSTED_PROXIES", ""))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	cfg.trustedProxies = trustedProxies
+
+	if cfg.auth.twoFactor.secretKey == "" {
+		if cfg.env == "production" {
+			logger.Fatal("TWO_FACTOR_SECRET_KEY must be set in production")
+		}
+		logger.Warn("TWO_FACTOR_SECRET_KEY is not set, TOTP secrets are encrypted with a development key")
+		cfg.auth.twoFactor.secretKey = developmentTwoFactorKey
+	}
+
+	if cfg.exports.secret == "" {
+		if cfg.env == "production" {
+			logger.Fatal("EXPORT_LINK_SECRET must be set in production")
+		}
+
+		secret, err := randomSecret()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		cfg.exports.secret = secret
+		logger.Warn("EXPORT_LINK_SECRET is not set, export links are signed with a random key and stop working when the server restarts")
+	}
+
 	// Database
 	db, err := db.New(
 		cfg.db.addr,
@@ -128,6 +205,20 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	invitationLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.invitationRateLimiter.RequestsPerTimeFrame,
+		cfg.invitationRateLimiter.TimeFrame,
+	)
+
+	var accountLockout, ipLockout lockout.Tracker
+	if cfg.redisCfg.enabled {
+		accountLockout = lockout.NewRedisTracker(rdb, cfg.loginLockout.account)
+		ipLockout = lockout.NewRedisTracker(rdb, cfg.loginLockout.ip)
+	} else {
+		accountLockout = lockout.NewMemoryTracker(cfg.loginLockout.account)
+		ipLockout = lockout.NewMemoryTracker(cfg.loginLockout.ip)
+	}
+
 	mongo := mongodb.NewMongoStorage(client.Database("analytics"))
 	store := store.NewStorage(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
@@ -137,17 +228,29 @@ func main() {
 		cfg.mail.fromEmail,
 	)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.aud, cfg.auth.token.iss)
+	jwtAuthenticator, err := newAuthenticator(cfg.auth.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if cfg.auth.token.signingKeyFile == "" {
+		logger.Warn("JWT_SIGNING_KEY_FILE is not set, tokens are signed with the shared HS256 secret")
+	}
+
+	oauthProviders := newOAuthProviders(cfg.auth.oauth, logger)
 
 	app := &application{
-		config:        cfg,
-		store:         store,
-		mongo:         mongo,
-		cacheStore:    cacheStorage,
-		logger:        logger,
-		mailer:        mailer,
-		authenticator: jwtAuthenticator,
-		rateLimiter:   rateLimiter,
+		config:            cfg,
+		store:             store,
+		mongo:             mongo,
+		cacheStore:        cacheStorage,
+		logger:            logger,
+		mailer:            mailer,
+		authenticator:     jwtAuthenticator,
+		rateLimiter:       rateLimiter,
+		invitationLimiter: invitationLimiter,
+		oauthProviders:    oauthProviders,
+		accountLockout:    accountLockout,
+		ipLockout:         ipLockout,
 	}
 
 	//metrics
@@ -162,3 +265,64 @@ func main() {
 	mux := app.mount()
 	logger.Fatal(app.run(mux))
 }
+
+// newAuthenticator signs tokens with the asymmetric key from
+// JWT_SIGNING_KEY_FILE when it is set and falls back to the HS256 secret.
+func newAuthenticator(cfg tokenConfig) (*auth.JWTAuthenticator, error) {
+	if cfg.signingKeyFile == "" {
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.aud, cfg.iss), nil
+	}
+
+	signingKey, err := auth.LoadSigningKey(cfg.signingKeyID, cfg.signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationKeys, err := auth.LoadVerificationKeys(cfg.verificationKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewJWTKeySetAuthenticator(signingKey, verificationKeys, cfg.aud, cfg.iss), nil
+}
+
+// oauthProviderConfigs enables the providers whose client id is set.
+func oauthProviderConfigs() []auth.OAuthProviderConfig {
+	google := auth.GoogleProvider
+	google.ClientID = env.GetString("GOOGLE_CLIENT_ID", "")
+	google.ClientSecret = env.GetString("GOOGLE_CLIENT_SECRET", "")
+
+	github := auth.GitHubProvider
+	github.ClientID = env.GetString("GITHUB_CLIENT_ID", "")
+	github.ClientSecret = env.GetString("GITHUB_CLIENT_SECRET", "")
+
+	var configs []auth.OAuthProviderConfig
+	for _, cfg := range []auth.OAuthProviderConfig{google, github} {
+		if cfg.ClientID != "" {
+			configs = append(configs, cfg)
+		}
+	}
+
+	return configs
+}
+
+// newOAuthProviders skips providers whose discovery fails, so that an
+// unreachable provider does not keep the API from starting.
+func newOAuthProviders(configs []auth.OAuthProviderConfig, logger *zap.SugaredLogger) map[string]auth.OAuthProvider {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	providers := make(map[string]auth.OAuthProvider, len(configs))
+	for _, cfg := range configs {
+		provider, err := auth.NewOAuthProvider(ctx, cfg, client)
+		if err != nil {
+			logger.Errorw("failed to set up oauth provider", "provider", cfg.Name, "error", err.Error())
+			continue
+		}
+		providers[cfg.Name] = provider
+	}
+
+	return providers
+}
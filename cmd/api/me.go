@@ -0,0 +1,397 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type UpdateMePayload struct {
+	Username  *string `json:"username" validate:"omitempty,min=1,max=100"`
+	Bio       *string `json:"bio" validate:"omitempty,max=500"`
+	AvatarURL *string `json:"avatar_url" validate:"omitempty,max=255,eq=|http_url"`
+	Website   *string `json:"website" validate:"omitempty,max=255,eq=|http_url"`
+	Location  *string `json:"location" validate:"omitempty,max=100"`
+	IsPrivate *bool   `json:"is_private"`
+}
+
+// ChangePasswordPayload changes the password of an account that has one.
+// Accounts created with an identity provider set their first password with
+// a password reset.
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=72"`
+}
+
+// DeleteMePayload confirms the deletion of the account. Password is required
+// when the account has one, Code when two-factor authentication is enabled.
+type DeleteMePayload struct {
+	Password string `json:"password" validate:"max=72"`
+	Code     string `json:"code" validate:"max=20"`
+}
+
+type ChangeEmailPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+const changeEmailMessage = "check the new address to confirm the change"
+
+// errNoPassword is returned to accounts created with an identity provider,
+// which have no password to confirm changes with.
+var errNoPassword = errors.New("the account has no password, set one with a password reset first")
+
+// GetMe godoc
+//
+//	@Summary		Get current user
+//	@Description	get the profile of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.User
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getMeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getUserFromContext(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateMe godoc
+//
+//	@Summary		Update current user
+//	@Description	update the profile of the current user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateMePayload	true	"Profile fields to change"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateMePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := *getUserFromContext(r)
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.Update(ctx, &user); err != nil {
+		switch err {
+		case store.ErrorDuplicatedUsername:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	// a public account has no use for requests, everyone may follow it
+	if !user.IsPrivate && payload.IsPrivate != nil {
+		requesterIds, err := app.store.Followers.ApproveFollowRequests(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		for _, requesterId := range requesterIds {
+			app.invalidateTimeline(ctx, requesterId)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ChangePassword godoc
+//
+//	@Summary		Change password
+//	@Description	change the password of the current user. Every other session is signed out. Accounts without a password set one with a password reset
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	ChangePasswordPayload	true	"Current and new password"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [put]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	// the user in the context carries no password hash
+	current, err := app.store.Users.GetByEmail(ctx, user.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !current.Password.IsSet() {
+		app.badRequestErrorResponse(w, r, errNoPassword)
+		return
+	}
+
+	if !current.Password.VerifyPassword(payload.CurrentPassword) {
+		app.forbiddenErrorResponse(w, r, errors.New("current password is incorrect"))
+		return
+	}
+
+	var password store.Password
+	if err := password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sessionId, _ := getClaimsFromContext(r)["sid"].(string)
+
+	if err := app.store.Users.ChangePassword(ctx, user.ID, &password, sessionId); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangeEmail godoc
+//
+//	@Summary		Change email
+//	@Description	send a confirmation link to the new address. The email changes once the link is used
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email"
+//	@Success		202		{string}	string				"Confirmation sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err := app.store.Users.CreateEmailChange(r.Context(), user.ID, payload.Email, hashToken, app.config.mail.emailChangeExpiry)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.sendEmailChange(user, payload.Email, plainToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, changeEmailMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) sendEmailChange(user *store.User, email, plainToken string) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  app.config.mail.emailChangeExpiry.String(),
+	}
+
+	return app.mailer.Send(
+		mailer.EmailChange,
+		user.Username,
+		email,
+		vars,
+		!isProdEnv,
+	)
+}
+
+// ConfirmEmailChange godoc
+//
+//	@Summary		Confirm email change
+//	@Description	switch the account to the new email using the emailed token
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path	string	true	"Confirmation token"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Router			/users/email/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		app.badRequestErrorResponse(w, r, errors.New("empty token"))
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		case store.ErrorDuplicatedEmail:
+			app.conflictErrorResponse(w, r, errors.New("an account with this email already exists"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMe godoc
+//
+//	@Summary		Delete current user
+//	@Description	delete the account of the current user together with their posts and comments. The password, and a TOTP or recovery code when two-factor authentication is enabled, confirm it. Accounts without a password confirm it with the code alone, or set a password with a password reset first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	DeleteMePayload	true	"Password and two-factor code"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DeleteMePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	// the user in the context carries no password hash
+	current, err := app.store.Users.GetByEmail(ctx, user.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	switch {
+	case current.Password.IsSet():
+		if !current.Password.VerifyPassword(payload.Password) {
+			app.forbiddenErrorResponse(w, r, errors.New("password is incorrect"))
+			return
+		}
+	case !user.TwoFactorEnabled:
+		// without a password the two-factor code is the only confirmation
+		app.badRequestErrorResponse(w, r, errNoPassword)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		valid, err := app.verifySecondFactor(ctx, user.ID, payload.Code)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !valid {
+			app.forbiddenErrorResponse(w, r, errInvalidTwoFactorCode)
+			return
+		}
+	}
+
+	if err := app.store.Users.Delete(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMe(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should return the current user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Username: "test"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/me", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should reject a taken username", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Username: "test"}, nil)
+		mockUserStore.On("Update", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+			return u.Username == "taken"
+		})).Return(store.ErrorDuplicatedUsername).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPatch, "/v1/users/me", testToken, UpdateMePayload{Username: &[]string{"taken"}[0]}), mux)
+
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should approve the pending follow requests when the account goes public", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, IsPrivate: true}, nil)
+		mockUserStore.On("Update", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+			return !u.IsPrivate
+		})).Return(nil).Once()
+		mockFollowerStore.On("ApproveFollowRequests", mock.Anything, int64(1)).Return([]int64{2, 3}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPatch, "/v1/users/me", testToken, UpdateMePayload{IsPrivate: &[]bool{false}[0]}), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockFollowerStore.AssertExpectations(t)
+	})
+
+	t.Run("should only accept http links in the profile", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPatch, "/v1/users/me", testToken, UpdateMePayload{Website: &[]string{"javascript:alert(1)"}[0]}), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockUserStore.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should require the current password to change it", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/me/password", testToken, ChangePasswordPayload{
+			CurrentPassword: "wrong",
+			NewPassword:     "new-password",
+		}), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should change the password", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com"}, nil)
+		mockUserStore.On("ChangePassword", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/me/password", testToken, ChangePasswordPayload{
+			CurrentPassword: "test",
+			NewPassword:     "new-password",
+		}), mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should mail a confirmation to the new address", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Username: "test"}, nil)
+		mockUserStore.On("CreateEmailChange", mock.Anything, int64(1), "new@example.com", mock.Anything, mock.Anything).Return(nil).Once()
+		mockMailer.On("Send", mailer.EmailChange, "test", "new@example.com", mock.Anything, true).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPost, "/v1/users/me/email", testToken, ChangeEmailPayload{Email: "new@example.com"}), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should confirm an email change", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("ConfirmEmailChange", mock.Anything, "good-token").Return(&store.User{ID: 1}, nil).Once()
+		mockUserStore.On("ConfirmEmailChange", mock.Anything, "bad-token").Return(nil, store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/email/good-token", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/email/bad-token", testToken, nil), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should require the password to delete the account", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me", testToken, DeleteMePayload{Password: "wrong"}), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should require a two-factor code to delete the account when enabled", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com", TwoFactorEnabled: true}, nil)
+		mockTwoFactorStore.On("GetSecret", mock.Anything, int64(1)).Return("", store.ErrorNotFound)
+		mockTwoFactorStore.On("UseRecoveryCode", mock.Anything, int64(1), mock.Anything).Return(store.ErrorNotFound).Once()
+		mockTwoFactorStore.On("UseRecoveryCode", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+		mockUserStore.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me", testToken, DeleteMePayload{Password: "test"}), mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		mockUserStore.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me", testToken, DeleteMePayload{Password: "test", Code: "recovery-code"}), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockTwoFactorStore.AssertExpectations(t)
+	})
+
+	t.Run("should send an account without a password to the password reset", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = passwordlessUserStore{mockUserStore}
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com"}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/users/me/password", testToken, ChangePasswordPayload{NewPassword: "new-password"}), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		rr = executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me", testToken, DeleteMePayload{}), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		mockUserStore.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockUserStore.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should delete an account without a password with the two-factor code", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = passwordlessUserStore{mockUserStore}
+		app.store.TwoFactor = mockTwoFactorStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "K7iGd@example.com", TwoFactorEnabled: true}, nil)
+		mockTwoFactorStore.On("GetSecret", mock.Anything, int64(1)).Return("", store.ErrorNotFound)
+		mockTwoFactorStore.On("UseRecoveryCode", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+		mockUserStore.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me", testToken, DeleteMePayload{Code: "recovery-code"}), mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockTwoFactorStore.AssertExpectations(t)
+	})
+}
+
+// passwordlessUserStore stands for an account created with an identity
+// provider, which has no password.
+type passwordlessUserStore struct {
+	*store.MockUserStore
+}
+
+func (s passwordlessUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	return &store.User{ID: 1, Email: email, IsActive: true}, nil
+}
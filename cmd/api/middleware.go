@@ -9,6 +9,7 @@ import (
 	"social/internal/store"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
@@ -68,6 +69,22 @@ func (app *application) AuthTokenMiddleware() func(next http.Handler) http.Handl
 				return
 			}
 			parts := strings.Split(authHeader, " ")
+			if len(parts) == 2 && parts[0] == "ApiKey" {
+				ctx, err := app.authenticateAPIKey(r.Context(), parts[1])
+				switch {
+				case err == nil:
+					if user := ctx.Value(userCtxKey).(*store.User); user.IsBanned() {
+						app.accountBannedResponse(w, r, user)
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(ctx))
+				case errors.Is(err, store.ErrorNotFound):
+					app.unAuthorizedErrorResponse(w, r, errors.New("invalid API key"))
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				app.logger.Warn("invalid auth header")
 				app.unAuthorizedErrorResponse(w, r, errors.New("invalid auth header. Probably malformed"))
@@ -99,7 +116,42 @@ func (app *application) AuthTokenMiddleware() func(next http.Handler) http.Handl
 				return
 			}
 
+			if version, _ := claims["ver"].(float64); int(version) != user.TokenVersion {
+				app.unAuthorizedErrorResponse(w, r, errors.New("token has been revoked"))
+				return
+			}
+
+			if user.IsBanned() {
+				app.accountBannedResponse(w, r, user)
+				return
+			}
+
+			if sid, _ := claims["sid"].(string); sid != "" {
+				err := app.store.Sessions.Touch(ctx, sid, user.ID)
+				switch {
+				case errors.Is(err, store.ErrorNotFound):
+					app.unAuthorizedErrorResponse(w, r, errors.New("session has ended"))
+					return
+				case err != nil:
+					app.internalServerError(w, r, err)
+					return
+				}
+			}
+
+			if jti, _ := claims["jti"].(string); jti != "" {
+				revoked, err := app.isTokenRevoked(ctx, jti)
+				if err != nil {
+					app.internalServerError(w, r, err)
+					return
+				}
+				if revoked {
+					app.unAuthorizedErrorResponse(w, r, errors.New("token has been revoked"))
+					return
+				}
+			}
+
 			ctx = context.WithValue(ctx, userCtxKey, user)
+			ctx = context.WithValue(ctx, claimsCtxKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -108,7 +160,7 @@ func (app *application) AuthTokenMiddleware() func(next http.Handler) http.Handl
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
+			if allow, retryAfter := app.rateLimiter.Allow(clientIP(r)); !allow {
 				app.rateLimitExceededResponse(w, r, retryAfter.String())
 				return
 			}
@@ -118,7 +170,9 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+// checkPostOwnership lets the author through and anyone else whose role
+// grants the permission.
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromContext(r)
 		post := getPostFromContext(r)
@@ -128,7 +182,7 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(r.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -143,7 +197,9 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
-func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+// checkCommentOwnership lets the author through and anyone else whose role
+// grants the permission.
+func (app *application) checkCommentOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromContext(r)
 		comment := getCommentFromContext(r)
@@ -153,7 +209,7 @@ func (app *application) checkCommentOwnership(requiredRole string, next http.Han
 			return
 		}
 
-		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(r.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -167,14 +223,45 @@ func (app *application) checkCommentOwnership(requiredRole string, next http.Han
 	})
 }
 
-func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
-	role, err := app.store.Roles.GetByName(ctx, roleName)
+// requirePermission rejects users whose role does not grant the permission.
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.hasPermission(r.Context(), getUserFromContext(r), permission)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
 
-	if err != nil {
+			if !allowed {
+				app.forbiddenErrorResponse(w, r, errors.New("user has no privileges to perform this action"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// denySuspended keeps suspended users to reading. It wraps the routes that
+// create or change content.
+func (app *application) denySuspended(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := getUserFromContext(r); user.IsSuspended(time.Now()) {
+			app.accountSuspendedResponse(w, r, user)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) hasPermission(ctx context.Context, user *store.User, permission string) (bool, error) {
+	granted, err := app.store.Roles.HasPermission(ctx, user.Role.Name, permission)
+	if err != nil || !granted {
 		return false, err
 	}
 
-	return user.Role.Level >= role.Level, nil
+	return app.meetsTwoFactorPolicy(ctx, user)
 }
 
 func (app *application) getUserWithRedis(ctx context.Context, userId int64) (*store.User, error) {
@@ -202,6 +289,20 @@ func (app *application) getUserWithRedis(ctx context.Context, userId int64) (*st
 	return user, nil
 }
 
+func (app *application) isTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.IsRevoked(ctx, jti)
+	}
+
+	return app.cacheStore.RevokedTokens.IsRevoked(ctx, jti)
+}
+
+func (app *application) invalidateUserCache(ctx context.Context, userId int64) {
+	if app.config.redisCfg.enabled {
+		app.cacheStore.Users.Delete(ctx, userId)
+	}
+}
+
 func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		commentIdStr := chi.URLParam(r, "commentId")
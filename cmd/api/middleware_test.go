@@ -107,7 +107,8 @@ func TestCheckPostOwnership(t *testing.T) {
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
 		mockPostsStore.On("GetById", mock.Anything, int64(1)).Return(*post, nil)
 		mockPostsStore.On("Delete", mock.Anything, int64(1)).Return(nil)
-		mockRoleStore.On("GetByName", mock.Anything, mock.Anything).Return(&role, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permPostDeleteAny).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permTwoFactorRequired).Return(false, nil)
 
 		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1", nil)
 		if err != nil {
@@ -149,12 +150,6 @@ func TestCheckPostOwnership(t *testing.T) {
 			Level: 1,
 		}
 
-		requiredRole := store.Role{
-			Id:    3,
-			Name:  "admin",
-			Level: 3,
-		}
-
 		user.Role = role
 
 		post := &store.Post{
@@ -170,7 +165,7 @@ func TestCheckPostOwnership(t *testing.T) {
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
 		mockPostsStore.On("GetById", mock.Anything, int64(1)).Return(*post, nil)
-		mockRoleStore.On("GetByName", mock.Anything, mock.Anything).Return(&requiredRole, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "user", permPostDeleteAny).Return(false, nil)
 
 		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1", nil)
 		if err != nil {
@@ -278,7 +273,8 @@ func TestCheckCommentOwnership(t *testing.T) {
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
 		mockCommentsStore.On("GetById", mock.Anything, mock.Anything).Return(comment, nil)
-		mockRoleStore.On("GetByName", mock.Anything, "admin").Return(&role, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permCommentDeleteAny).Return(true, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "admin", permTwoFactorRequired).Return(false, nil)
 		mockCommentsStore.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 		req, err := http.NewRequest(http.MethodDelete, "/v1/comments/1", nil)
@@ -318,12 +314,6 @@ func TestCheckCommentOwnership(t *testing.T) {
 			Level: 1,
 		}
 
-		requiredRole := store.Role{
-			Id:    3,
-			Name:  "admin",
-			Level: 3,
-		}
-
 		user.Role = role
 
 		comment := &store.Comment{
@@ -335,7 +325,7 @@ func TestCheckCommentOwnership(t *testing.T) {
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
 		mockCommentsStore.On("GetById", mock.Anything, int64(1)).Return(comment, nil)
-		mockRoleStore.On("GetByName", mock.Anything, mock.Anything).Return(&requiredRole, nil)
+		mockRoleStore.On("HasPermission", mock.Anything, "user", permCommentDeleteAny).Return(false, nil)
 
 		req, err := http.NewRequest(http.MethodDelete, "/v1/comments/1", nil)
 		if err != nil {
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	oauthCookieName   = "oauth_state"
+	oauthCookiePath   = "/v1/authentication/oauth"
+	oauthCookieMaxAge = 10 * time.Minute
+
+	oauthPendingActivationMessage = "check your email to activate the account"
+)
+
+var errOAuthEmailMissing = errors.New("the provider did not return an email address")
+
+// OAuthLogin godoc
+//
+//	@Summary		Start an OAuth login
+//	@Description	redirect to the provider's consent page using the authorization code flow with PKCE
+//	@Tags			auth
+//	@Param			provider	path	string	true	"Provider name, e.g. google or github"
+//	@Success		302
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Router			/authentication/oauth/{provider} [get]
+func (app *application) oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFoundErrorResponse(w, r, fmt.Errorf("unknown provider %q", name))
+		return
+	}
+
+	verifier, challenge, err := auth.GeneratePKCE()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	state := uuid.New().String()
+
+	// the verifier never leaves the user agent except towards our callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookieName,
+		Value:    state + "." + verifier,
+		Path:     oauthCookiePath,
+		MaxAge:   int(oauthCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, challenge, app.oauthRedirectURL(name)), http.StatusFound)
+}
+
+// OAuthCallback godoc
+//
+//	@Summary		Complete an OAuth login
+//	@Description	exchange the authorization code, link the external identity and create tokens. Accounts without a verified email have to be activated first
+//	@Tags			auth
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Param			code		query		string	true	"Authorization code"
+//	@Param			state		query		string	true	"State"
+//	@Success		201			{object}	AuthTokens
+//	@Success		202			{object}	TwoFactorChallenge
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//
+//	@Router			/authentication/oauth/{provider}/callback [get]
+func (app *application) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFoundErrorResponse(w, r, fmt.Errorf("unknown provider %q", name))
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		app.badRequestErrorResponse(w, r, fmt.Errorf("authorization failed: %s", errCode))
+		return
+	}
+
+	cookie, err := r.Cookie(oauthCookieName)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("missing oauth state"))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookieName,
+		Path:     oauthCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	state, verifier, found := strings.Cut(cookie.Value, ".")
+	if !found || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.badRequestErrorResponse(w, r, errors.New("invalid oauth state"))
+		return
+	}
+
+	ctx := r.Context()
+
+	identity, err := provider.Exchange(ctx, query.Get("code"), verifier, app.oauthRedirectURL(name))
+	if err != nil {
+		app.logger.Warnw("oauth exchange failed", "provider", name, "error", err.Error())
+		app.unAuthorizedErrorResponse(w, r, errors.New("oauth exchange failed"))
+		return
+	}
+
+	user, err := app.userFromIdentity(ctx, identity)
+	if err != nil {
+		switch err {
+		case errOAuthEmailMissing:
+			app.badRequestErrorResponse(w, r, err)
+		case store.ErrorDuplicatedEmail:
+			app.conflictErrorResponse(w, r, errors.New("an account with this email already exists"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !user.IsActive {
+		if err := app.jsonResponse(w, http.StatusAccepted, oauthPendingActivationMessage); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.IsBanned() {
+		app.accountBannedResponse(w, r, user)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		app.createTwoFactorChallenge(w, r, user)
+		return
+	}
+
+	app.issueAuthTokens(w, r, user)
+}
+
+// userFromIdentity returns the user linked to the external identity. A new
+// identity is linked to the account with the same email when the provider
+// verified it, otherwise a new account is created.
+func (app *application) userFromIdentity(ctx context.Context, external *auth.ExternalIdentity) (*store.User, error) {
+	identity, err := app.store.UserIdentities.GetByProvider(ctx, external.Provider, external.Subject)
+	switch err {
+	case nil:
+		return app.store.Users.GetById(ctx, identity.UserId)
+	case store.ErrorNotFound:
+	default:
+		return nil, err
+	}
+
+	if external.Email == "" {
+		return nil, errOAuthEmailMissing
+	}
+
+	identity = &store.UserIdentity{
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	}
+
+	if external.EmailVerified {
+		user, err := app.store.Users.GetByEmail(ctx, external.Email)
+		switch err {
+		case nil:
+			identity.UserId = user.ID
+			if err := app.store.UserIdentities.Create(ctx, identity); err != nil {
+				return nil, err
+			}
+			return app.store.Users.GetById(ctx, user.ID)
+		case store.ErrorNotFound:
+		default:
+			return nil, err
+		}
+	}
+
+	return app.createUserFromIdentity(ctx, external, identity)
+}
+
+// createUserFromIdentity skips the invitation when the provider verified the
+// email address.
+func (app *application) createUserFromIdentity(ctx context.Context, external *auth.ExternalIdentity, identity *store.UserIdentity) (*store.User, error) {
+	username := strings.TrimSpace(external.Name)
+	if username == "" {
+		username, _, _ = strings.Cut(external.Email, "@")
+	}
+	if len(username) > 90 {
+		username = username[:90]
+	}
+
+	user := &store.User{
+		Username: username,
+		Email:    external.Email,
+	}
+
+	var plainToken, hashToken string
+	if !external.EmailVerified {
+		plainToken = uuid.New().String()
+		hash := sha256.Sum256([]byte(plainToken))
+		hashToken = hex.EncodeToString(hash[:])
+	}
+
+	// usernames are unique, so retry with a suffix when the name is taken
+	for attempt := 0; ; attempt++ {
+		err := app.store.Users.CreateFromIdentity(ctx, user, identity, hashToken, app.config.mail.expiry)
+		if err == nil {
+			break
+		}
+		if err != store.ErrorDuplicatedUsername || attempt == 2 {
+			return nil, err
+		}
+		user.Username = username + "-" + uuid.New().String()[:8]
+	}
+
+	if plainToken != "" {
+		if err := app.sendInvitation(user, plainToken); err != nil {
+			app.logger.Errorw("failed to send invitation", "user_id", user.ID, "error", err.Error())
+		}
+	}
+
+	return user, nil
+}
+
+func (app *application) oauthRedirectURL(provider string) string {
+	return fmt.Sprintf("%s%s/%s/callback", app.config.apiUrl, oauthCookiePath, provider)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"social/internal/auth"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider that checks the PKCE
+// verifier against the challenge of the authorization request.
+type fakeIssuer struct {
+	*httptest.Server
+	challenge     string
+	emailVerified bool
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	issuer := &fakeIssuer{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"userinfo_endpoint":      issuer.URL + "/userinfo",
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || auth.PKCEChallenge(r.FormValue("code_verifier")) != issuer.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"access_token": "fake-access-token", "token_type": "Bearer"})
+	})
+
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fake-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"sub":            "42",
+			"email":          "oidc@example.com",
+			"email_verified": issuer.emailVerified,
+			"name":           "oidc",
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func TestOAuthLogin(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	app := newTestApplication(t, config{apiUrl: "http://localhost:8080"})
+	provider, err := auth.NewOAuthProvider(context.Background(), auth.OAuthProviderConfig{
+		Name:     "fake",
+		ClientID: "client",
+		Issuer:   issuer.URL,
+		Scopes:   []string{"openid", "email"},
+	}, issuer.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.oauthProviders = map[string]auth.OAuthProvider{"fake": provider}
+	mux := app.mount()
+
+	// login follows the redirect to the provider and returns the callback
+	// request the provider would send the user back with
+	login := func(t *testing.T, code string) *http.Request {
+		rr := executeRequest(newJSONRequest(t, http.MethodGet, "/v1/authentication/oauth/fake", nil), mux)
+		checkResponseCode(t, http.StatusFound, rr.Code)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := location.Query()
+		if params.Get("code_challenge_method") != "S256" {
+			t.Fatalf("expected a S256 challenge, got %q", location)
+		}
+		issuer.challenge = params.Get("code_challenge")
+
+		callback := "/v1/authentication/oauth/fake/callback?" + url.Values{
+			"code":  {code},
+			"state": {params.Get("state")},
+		}.Encode()
+		req := newJSONRequest(t, http.MethodGet, callback, nil)
+		for _, cookie := range rr.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		return req
+	}
+
+	t.Run("should link a verified email to the existing account", func(t *testing.T) {
+		issuer.emailVerified = true
+
+		mockUserStore := new(store.MockUserStore)
+		mockIdentityStore := new(store.MockUserIdentityStore)
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.Users = mockUserStore
+		app.store.UserIdentities = mockIdentityStore
+		app.store.RefreshTokens = mockRefreshTokenStore
+
+		mockIdentityStore.On("GetByProvider", mock.Anything, "fake", "42").Return(nil, store.ErrorNotFound).Once()
+		mockIdentityStore.On("Create", mock.Anything, mock.MatchedBy(func(i *store.UserIdentity) bool {
+			return i.UserId == 1 && i.Subject == "42"
+		})).Return(nil).Once()
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, IsActive: true}, nil)
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		rr := executeRequest(login(t, "good-code"), mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		mockIdentityStore.AssertExpectations(t)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should invite a new account with an unverified email", func(t *testing.T) {
+		issuer.emailVerified = false
+
+		mockUserStore := new(store.MockUserStore)
+		mockIdentityStore := new(store.MockUserIdentityStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.store.UserIdentities = mockIdentityStore
+		app.mailer = mockMailer
+
+		mockIdentityStore.On("GetByProvider", mock.Anything, "fake", "42").Return(nil, store.ErrorNotFound).Once()
+		mockUserStore.On("CreateFromIdentity", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(token string) bool {
+			return token != ""
+		}), mock.Anything).Return(nil).Once()
+		mockMailer.On("Send", mailer.UserWelcome, "oidc", "oidc@example.com", mock.Anything, true).Return(nil).Once()
+
+		rr := executeRequest(login(t, "good-code"), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+
+		var body struct {
+			Data string `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data != oauthPendingActivationMessage {
+			t.Errorf("unexpected response %q", body.Data)
+		}
+		mockUserStore.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should reject a code the provider does not accept", func(t *testing.T) {
+		rr := executeRequest(login(t, "bad-code"), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should reject a mismatching state", func(t *testing.T) {
+		req := login(t, "good-code")
+		query := req.URL.Query()
+		query.Set("state", "forged")
+		req.URL.RawQuery = query.Encode()
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
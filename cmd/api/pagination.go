@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"strings"
+)
+
+// Page holds the cursors of the pages around the one in a response. A cursor
+// is empty when there is nothing on that side.
+type Page struct {
+	Next string
+	Prev string
+}
+
+func parseKeysetQuery(r *http.Request) (store.PaginatedKeysetQuery, error) {
+	q := store.PaginatedKeysetQuery{Limit: 20}
+	q, err := q.Parse(r)
+	if err != nil {
+		return q, err
+	}
+
+	return q, Validate.Struct(q)
+}
+
+// paginate trims the extra row fetched past the limit and works out the
+// cursors of the neighbouring pages. Without a cursor the page is the first
+// one, unless skipped says an offset moved past the start.
+func paginate[T interface{ Cursor() store.Cursor }](entries []T, limit int, cursor *store.Cursor, skipped bool) ([]T, Page) {
+	backward := cursor != nil && cursor.Before
+
+	// the extra row is the one furthest from the cursor
+	more := len(entries) > limit
+	if more {
+		if backward {
+			entries = entries[len(entries)-limit:]
+		} else {
+			entries = entries[:limit]
+		}
+	}
+
+	var page Page
+	if len(entries) == 0 {
+		return entries, page
+	}
+
+	first := entries[0].Cursor()
+	first.Before = true
+	last := entries[len(entries)-1].Cursor()
+
+	if backward {
+		page.Next = last.Encode()
+		if more {
+			page.Prev = first.Encode()
+		}
+	} else {
+		if more {
+			page.Next = last.Encode()
+		}
+		if cursor != nil || skipped {
+			page.Prev = first.Encode()
+		}
+	}
+
+	return entries, page
+}
+
+// setLinkHeader points to the neighbouring pages in an RFC 8288 Link header.
+// The links keep the other query parameters and switch offsets to cursors.
+func (app *application) setLinkHeader(w http.ResponseWriter, r *http.Request, page Page) {
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", page.Next}, {"prev", page.Prev}} {
+		if link.cursor == "" {
+			continue
+		}
+
+		qs := r.URL.Query()
+		qs.Del("offset")
+		qs.Set("cursor", link.cursor)
+		links = append(links, fmt.Sprintf(`<%s%s?%s>; rel="%s"`, app.config.apiUrl, r.URL.Path, qs.Encode(), link.rel))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
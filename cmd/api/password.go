@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+const forgotPasswordMessage = "if an account with this email exists, a password reset link has been sent"
+
+// ForgotPassword godoc
+//
+//	@Summary		Forgot password
+//	@Description	send a password reset link to the given email. The response does not reveal whether the account exists
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"Account email"
+//	@Success		202		{string}	string					"Reset link sent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	// the account is looked up after the response, which then takes as long
+	// for an unknown email as for a known one
+	app.runInBackground(func() {
+		if err := app.sendPasswordReset(context.Background(), payload.Email); err != nil {
+			app.logger.Errorw("failed to send password reset", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, forgotPasswordMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// sendPasswordReset emails a reset link to the account with the email, if
+// there is one.
+func (app *application) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	switch err {
+	case nil:
+	case store.ErrorNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err = app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken, app.config.mail.passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: app.config.mail.passwordResetExpiry.String(),
+	}
+
+	return app.mailer.Send(
+		mailer.PasswordReset,
+		user.Username,
+		user.Email,
+		vars,
+		!isProdEnv,
+	)
+}
+
+// ResetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	set a new password using a reset token. All existing sessions are signed out
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	path	string					true	"Reset token"
+//	@Param			payload	body	ResetPasswordPayload	true	"New password"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Router			/authentication/password/reset/{token} [put]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		app.badRequestErrorResponse(w, r, errors.New("empty token"))
+		return
+	}
+
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	var password store.Password
+	if err := password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.ResetPassword(ctx, token, &password)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
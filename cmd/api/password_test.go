@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	mailer "social/internal/mailer"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestForgotPassword(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: false,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	mux := app.mount()
+
+	t.Run("should send a password reset email", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		mockUserStore.On("CreatePasswordReset", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+		mockMailer.On("Send", mailer.PasswordReset, "test", "K7iGd@example.com", mock.Anything, true).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/password/forgot", ForgotPasswordPayload{Email: "K7iGd@example.com"}), mux)
+		app.background.Wait()
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		mockUserStore.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should answer before the email is sent", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		sent := make(chan time.Time)
+		mockUserStore.On("CreatePasswordReset", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+		mockMailer.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).WaitUntil(sent).Return(nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/password/forgot", ForgotPasswordPayload{Email: "K7iGd@example.com"}), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+		close(sent)
+		app.background.Wait()
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("should not reveal a failure to send the email", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockMailer := new(mailer.MockMailer)
+		app.store.Users = mockUserStore
+		app.mailer = mockMailer
+
+		mockUserStore.On("CreatePasswordReset", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(nil).Once()
+		mockMailer.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("sendgrid down")).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPost, "/v1/authentication/password/forgot", ForgotPasswordPayload{Email: "K7iGd@example.com"}), mux)
+		app.background.Wait()
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: false,
+		},
+	}
+	app := newTestApplication(t, withRedis)
+	mux := app.mount()
+
+	t.Run("should reset the password", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("ResetPassword", mock.Anything, "valid-token", mock.MatchedBy(func(p *store.Password) bool {
+			return p.VerifyPassword("new-password")
+		})).Return(&store.User{ID: 1}, nil).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPut, "/v1/authentication/password/reset/"+"valid-token", ResetPasswordPayload{Password: "new-password"}), mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should not reset the password with an invalid token", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("ResetPassword", mock.Anything, "used-token", mock.Anything).Return(nil, store.ErrorNotFound).Once()
+
+		rr := executeRequest(newJSONRequest(t, http.MethodPut, "/v1/authentication/password/reset/"+"used-token", ResetPasswordPayload{Password: "new-password"}), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -63,6 +63,15 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 	app.mongo.Tags.UpdateTagsUsage(ctx, post.Tags)
 
+	// the post is saved either way, a missed push only leaves it out of
+	// timelines until they are rebuilt
+	saved := *post
+	app.runInBackground(func() {
+		if err := app.fanOutPost(context.Background(), &saved); err != nil {
+			app.logger.Errorw("failed to push post to timelines", "post_id", saved.ID, "error", err.Error())
+		}
+	})
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -91,7 +100,20 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID)
+	ctx := r.Context()
+	viewer := getUserFromContext(r)
+
+	visible, err := app.canViewContentOf(ctx, viewer, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFoundErrorResponse(w, r, store.ErrorNotFound)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostId(ctx, post.ID, viewer.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
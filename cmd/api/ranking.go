@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"social/internal/store"
+	"time"
+)
+
+type feedLister func(ctx context.Context, userId int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error)
+
+// rankFeed reads the newest posts of the feed within the ranking window and
+// returns the page of fq ranked by score. Scores are taken at now, so the
+// same posts always rank the same at the same time.
+func (app *application) rankFeed(ctx context.Context, userId int64, fq store.PaginatedFeedQuery, list feedLister, now time.Time) ([]store.PostWithMetadata, error) {
+	cfg := app.config.ranking
+
+	candidates := fq
+	candidates.Sort = "desc"
+	candidates.Limit = cfg.candidates
+	candidates.Offset = 0
+	candidates.Cursor = nil
+
+	since := now.Add(-cfg.window)
+	if fq.Since == nil || fq.Since.Before(since) {
+		candidates.Since = &since
+	}
+
+	posts, err := list(ctx, userId, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 || fq.Offset >= len(posts) {
+		return []store.PostWithMetadata{}, nil
+	}
+
+	authorIds := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		if !slices.Contains(authorIds, post.UserId) {
+			authorIds = append(authorIds, post.UserId)
+		}
+	}
+
+	affinities, err := app.store.Posts.GetAuthorAffinities(ctx, userId, authorIds)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int64]float64, len(posts))
+	for _, post := range posts {
+		scores[post.ID] = cfg.score(post, affinities[post.UserId], now)
+	}
+
+	// ids break ties, so the order never depends on how the posts were read
+	slices.SortFunc(posts, func(a, b store.PostWithMetadata) int {
+		if c := cmp.Compare(scores[b.ID], scores[a.ID]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	end := min(fq.Offset+fq.Limit, len(posts))
+	return posts[fq.Offset:end], nil
+}
+
+// score weighs the comments and reactions on the post and the viewer's
+// affinity to its author, and lets it decay with the age of the post in
+// hours.
+func (cfg rankingConfig) score(post store.PostWithMetadata, affinity store.AuthorAffinity, now time.Time) float64 {
+	closeness := math.Log1p(float64(affinity.Interactions))
+	if affinity.Follows {
+		closeness++
+	}
+
+	points := 1 +
+		cfg.commentWeight*float64(post.CommentsCount) +
+		cfg.reactionWeight*float64(post.Reactions.Total()) +
+		cfg.affinityWeight*closeness
+
+	age := max(now.Sub(post.Cursor().CreatedAt).Hours(), 0)
+	return points / math.Pow(age+2, cfg.gravity)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRankFeed(t *testing.T) {
+	app := newTestApplication(t, config{
+		ranking: rankingConfig{commentWeight: 1, reactionWeight: 0.5, affinityWeight: 2, gravity: 1.5, window: 24 * time.Hour, candidates: 50},
+	})
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	post := func(id, authorId int64, age time.Duration, comments int) store.PostWithMetadata {
+		return store.PostWithMetadata{
+			Post:          store.Post{ID: id, UserId: authorId, CreatedAt: now.Add(-age).Format(time.RFC3339Nano)},
+			CommentsCount: comments,
+		}
+	}
+
+	// read newest first, as the feed returns them
+	candidates := []store.PostWithMetadata{
+		post(1, 2, time.Hour, 0),
+		post(2, 3, 2*time.Hour, 0),
+		post(3, 3, 2*time.Hour, 0),
+		post(4, 2, 3*time.Hour, 10),
+	}
+
+	setup := func(t *testing.T) feedLister {
+		mockPostStore := new(store.MockPostStore)
+		app.store.Posts = mockPostStore
+		mockPostStore.On("GetAuthorAffinities", mock.Anything, int64(1), []int64{2, 3}).Return(map[int64]store.AuthorAffinity{
+			3: {Follows: true, Interactions: 4},
+		}, nil)
+
+		return func(ctx context.Context, userId int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+			if fq.Sort != "desc" || fq.Limit != 50 || fq.Offset != 0 || !fq.Since.Equal(now.Add(-24*time.Hour)) {
+				t.Errorf("unexpected candidate query %+v", fq)
+			}
+			return append([]store.PostWithMetadata{}, candidates...), nil
+		}
+	}
+
+	ids := func(posts []store.PostWithMetadata) []int64 {
+		ids := make([]int64, len(posts))
+		for i, p := range posts {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+
+	t.Run("should rank by comments, affinity and age with ties broken by id", func(t *testing.T) {
+		list := setup(t)
+
+		posts, err := app.rankFeed(ctx, 1, store.PaginatedFeedQuery{Limit: 4, Sort: store.SortTop}, list, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int64{4, 3, 2, 1}
+		if got := ids(posts); !slices.Equal(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("should page the ranking by offset", func(t *testing.T) {
+		list := setup(t)
+
+		posts, err := app.rankFeed(ctx, 1, store.PaginatedFeedQuery{Limit: 2, Offset: 2, Sort: store.SortTop}, list, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int64{2, 1}
+		if got := ids(posts); !slices.Equal(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("should count reactions towards the score", func(t *testing.T) {
+		setup(t)
+		reacted := post(2, 3, 2*time.Hour, 0)
+		reacted.Reactions = store.ReactionCounts{"like": 3, "❤️": 2}
+		list := func(ctx context.Context, userId int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+			return []store.PostWithMetadata{post(3, 3, 2*time.Hour, 0), reacted}, nil
+		}
+		app.store.Posts.(*store.MockPostStore).On("GetAuthorAffinities", mock.Anything, int64(1), []int64{3}).Return(map[int64]store.AuthorAffinity{}, nil)
+
+		posts, err := app.rankFeed(ctx, 1, store.PaginatedFeedQuery{Limit: 2, Sort: store.SortTop}, list, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int64{2, 3}
+		if got := ids(posts); !slices.Equal(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+}
+
+func TestTopFeed(t *testing.T) {
+	app := newTestApplication(t, config{
+		ranking: rankingConfig{commentWeight: 1, affinityWeight: 2, gravity: 1.5, window: 24 * time.Hour, candidates: 50},
+	})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should return the ranked feed without cursors", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockPostStore := new(store.MockPostStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostStore
+
+		now := time.Now()
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockPostStore.On("GetUserFeed", mock.Anything, int64(1), mock.MatchedBy(func(fq store.PaginatedFeedQuery) bool {
+			return fq.Sort == "desc" && fq.Limit == 50
+		})).Return([]store.PostWithMetadata{
+			{Post: store.Post{ID: 1, UserId: 2, CreatedAt: now.Add(-time.Hour).Format(time.RFC3339Nano)}},
+			{Post: store.Post{ID: 2, UserId: 2, CreatedAt: now.Add(-2 * time.Hour).Format(time.RFC3339Nano)}, CommentsCount: 20},
+		}, nil).Once()
+		mockPostStore.On("GetAuthorAffinities", mock.Anything, int64(1), []int64{2}).Return(map[int64]store.AuthorAffinity{}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/feed?sort=top&limit=1", testToken, nil), mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data       []store.PostWithMetadata `json:"data"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(response.Data) != 1 || response.Data[0].ID != 2 {
+			t.Errorf("expected the commented post first, got %+v", response.Data)
+		}
+		if response.NextCursor != "" {
+			t.Errorf("expected no cursor on a ranked page")
+		}
+		mockPostStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject a cursor on a ranked feed", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		cursor := store.Cursor{CreatedAt: time.Now(), ID: 3}
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/feed?sort=top&cursor="+cursor.Encode(), testToken, nil), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"social/internal/store"
+	"strings"
+)
+
+// reactionLike is always allowed, next to the configured emoji.
+const reactionLike = "like"
+
+type ReactPayload struct {
+	Reaction string `json:"reaction" validate:"required,max=32"`
+}
+
+// reactionSet returns the reactions users can choose from: a like and the
+// emoji in the comma-separated list.
+func reactionSet(emoji string) []string {
+	reactions := []string{reactionLike}
+	for _, e := range strings.Split(emoji, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" && !slices.Contains(reactions, e) {
+			reactions = append(reactions, e)
+		}
+	}
+	return reactions
+}
+
+// React godoc
+//
+//	@Summary		React to post
+//	@Description	react to a post with a like or one of the configured emoji, in place of the user's previous reaction
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postId	path	int				true	"Post ID"
+//	@Param			payload	body	ReactPayload	true	"Reaction"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postId}/reactions [put]
+func (app *application) reactHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ReactPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if !slices.Contains(app.config.reactions, payload.Reaction) {
+		app.badRequestErrorResponse(w, r, errors.New("unknown reaction"))
+		return
+	}
+
+	post, ok := app.visiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Reactions.React(r.Context(), post.ID, getUserFromContext(r).ID, payload.Reaction); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unreact godoc
+//
+//	@Summary		Remove reaction
+//	@Description	take back the user's reaction to a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postId	path	int	true	"Post ID"
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postId}/reactions [delete]
+func (app *application) unreactHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromContext(r)
+	if post == nil {
+		app.notFoundErrorResponse(w, r, errors.New("post not found in context"))
+		return
+	}
+
+	if err := app.store.Reactions.Unreact(r.Context(), post.ID, getUserFromContext(r).ID); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetReactions godoc
+//
+//	@Summary		List reactions
+//	@Description	list the users who reacted to a post with their reaction, newest first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postId	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Users per page"	default(20)
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of another page"
+//	@Success		200		{array}		store.Reactor
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postId}/reactions [get]
+func (app *application) getReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseKeysetQuery(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	post, ok := app.visiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	// one extra row tells whether there is another page
+	limit := q.Limit
+	q.Limit++
+	reactors, err := app.store.Reactions.GetReactors(r.Context(), post.ID, getUserFromContext(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	reactors, page := paginate(reactors, limit, q.Cursor, false)
+
+	if err := app.pagedJSONResponse(w, r, http.StatusOK, reactors, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// visiblePost returns the post of the request when the user may see it and
+// responds with not found otherwise.
+func (app *application) visiblePost(w http.ResponseWriter, r *http.Request) (*store.Post, bool) {
+	post := getPostFromContext(r)
+	if post == nil {
+		app.notFoundErrorResponse(w, r, errors.New("post not found in context"))
+		return nil, false
+	}
+
+	visible, err := app.canViewContentOf(r.Context(), getUserFromContext(r), post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	if !visible {
+		app.notFoundErrorResponse(w, r, store.ErrorNotFound)
+		return nil, false
+	}
+
+	return post, true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"social/internal/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReactions(t *testing.T) {
+	app := newTestApplication(t, config{reactions: reactionSet("❤️, 😂")})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(t *testing.T) *store.MockReactionStore {
+		mockUserStore := new(store.MockUserStore)
+		mockPostStore := new(store.MockPostStore)
+		mockReactionStore := new(store.MockReactionStore)
+		app.store.Users = mockUserStore
+		app.store.Posts = mockPostStore
+		app.store.Reactions = mockReactionStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("GetById", mock.Anything, int64(2)).Return(&store.User{ID: 2}, nil)
+		mockPostStore.On("GetById", mock.Anything, int64(7)).Return(store.Post{ID: 7, UserId: 2}, nil)
+		return mockReactionStore
+	}
+
+	t.Run("should react with a like or a configured emoji", func(t *testing.T) {
+		mockReactionStore := setup(t)
+		mockReactionStore.On("React", mock.Anything, int64(7), int64(1), "like").Return(nil).Once()
+		mockReactionStore.On("React", mock.Anything, int64(7), int64(1), "😂").Return(nil).Once()
+
+		for _, reaction := range []string{"like", "😂"} {
+			rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/posts/7/reactions", testToken, ReactPayload{Reaction: reaction}), mux)
+			checkResponseCode(t, http.StatusNoContent, rr.Code)
+		}
+		mockReactionStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject reactions outside of the set", func(t *testing.T) {
+		mockReactionStore := setup(t)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodPut, "/v1/posts/7/reactions", testToken, ReactPayload{Reaction: "🙃"}), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockReactionStore.AssertNotCalled(t, "React", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should not find a reaction to take back", func(t *testing.T) {
+		mockReactionStore := setup(t)
+		mockReactionStore.On("Unreact", mock.Anything, int64(7), int64(1)).Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/posts/7/reactions", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should list the reactors a page at a time", func(t *testing.T) {
+		mockReactionStore := setup(t)
+		now := time.Now()
+		mockReactionStore.On("GetReactors", mock.Anything, int64(7), int64(1), store.PaginatedKeysetQuery{Limit: 2}).Return([]store.Reactor{
+			{ID: 3, Reaction: "like", ReactedAt: now},
+			{ID: 4, Reaction: "❤️", ReactedAt: now.Add(-time.Minute)},
+		}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/posts/7/reactions?limit=1", testToken, nil), mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data       []store.Reactor `json:"data"`
+			NextCursor string          `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(response.Data) != 1 || response.Data[0].ID != 3 {
+			t.Errorf("expected the newest reactor, got %+v", response.Data)
+		}
+		if response.NextCursor == "" {
+			t.Errorf("expected a cursor to the next page")
+		}
+	})
+}
+
+func TestReactionSet(t *testing.T) {
+	reactions := reactionSet(" ❤️,,😂,❤️")
+
+	expected := []string{"like", "❤️", "😂"}
+	if strings.Join(reactions, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, reactions)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parseTrustedProxies reads a comma-separated list of the addresses and
+// networks of the proxies in front of the server.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	proxies := []netip.Prefix{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// realIPMiddleware replaces RemoteAddr with the address the trusted proxies
+// forwarded the request for. Requests that do not come through one of them
+// keep the address of the connection, so that clients cannot pick the
+// address they are rate limited and locked out by.
+func (app *application) realIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := app.forwardedFor(r); ok {
+			r.RemoteAddr = addr.String()
+		} else if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-IP") != "" {
+			app.untrustedForwardOnce.Do(func() {
+				app.logger.Warnw("ignoring forwarded client addresses from a proxy that is not trusted, set TRUSTED_PROXIES if the server runs behind one",
+					"peer", clientIP(r))
+			})
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedFor returns the client address forwarded by a trusted proxy. The
+// X-Forwarded-For hops are read from the right, as only the ones appended by
+// trusted proxies can be relied on.
+func (app *application) forwardedFor(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !app.isTrustedProxy(peer.Addr()) {
+		return netip.Addr{}, false
+	}
+
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		hops := strings.Split(header, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			if i == 0 || !app.isTrustedProxy(addr) {
+				return addr.Unmap(), true
+			}
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
+
+func (app *application) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, proxy := range app.config.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client. realIPMiddleware has already
+// replaced RemoteAddr with the address forwarded by a trusted proxy, which
+// has no port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApplication(t, config{trustedProxies: proxies})
+
+	clientAddr := func(t *testing.T, remoteAddr string, header http.Header) string {
+		req := newJSONRequest(t, http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header = header
+
+		var addr string
+		app.realIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr = clientIP(r)
+		})).ServeHTTP(nil, req)
+		return addr
+	}
+
+	t.Run("should ignore forwarding headers from clients", func(t *testing.T) {
+		addr := clientAddr(t, "203.0.113.7:41000", http.Header{
+			"X-Forwarded-For": {"198.51.100.1"},
+			"X-Real-Ip":       {"198.51.100.2"},
+		})
+		if addr != "203.0.113.7" {
+			t.Errorf("expected the address of the connection, got %q", addr)
+		}
+	})
+
+	t.Run("should take the first hop that is not a trusted proxy", func(t *testing.T) {
+		addr := clientAddr(t, "10.1.2.3:41000", http.Header{
+			"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 192.0.2.1"},
+		})
+		if addr != "203.0.113.7" {
+			t.Errorf("expected the address before the proxies, got %q", addr)
+		}
+	})
+
+	t.Run("should fall back to X-Real-IP from a trusted proxy", func(t *testing.T) {
+		addr := clientAddr(t, "192.0.2.1:41000", http.Header{
+			"X-Real-Ip": {"203.0.113.7"},
+		})
+		if addr != "203.0.113.7" {
+			t.Errorf("expected the real ip, got %q", addr)
+		}
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := parseTrustedProxies("10.0.0.0/8,not-an-address"); err == nil {
+		t.Error("expected an invalid address to be rejected")
+	}
+
+	proxies, err := parseTrustedProxies("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxies) != 0 {
+		t.Errorf("expected no trusted proxies, got %v", proxies)
+	}
+}
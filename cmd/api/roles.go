@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Permissions checked by the routes. Roles are granted them in the
+// role_permissions table.
+const (
+	permPostUpdateAny    = "post.update.any"
+	permPostDeleteAny    = "post.delete.any"
+	permCommentUpdateAny = "comment.update.any"
+	permCommentDeleteAny = "comment.delete.any"
+	permUsersManage      = "users.manage"
+	permUsersModerate    = "users.moderate"
+	permRolesManage      = "roles.manage"
+	// permTwoFactorRequired keeps the other permissions of a role from
+	// being used without two-factor authentication
+	permTwoFactorRequired = "two_factor.required"
+)
+
+// seededRoles are created by the migrations and relied on by registration,
+// so they cannot be deleted.
+var seededRoles = map[string]bool{"user": true, "moderator": true, "admin": true}
+
+type CreateRolePayload struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	Level       int    `json:"level" validate:"min=0"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+// GetRoles godoc
+//
+//	@Summary		List roles
+//	@Description	list roles with their permissions
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{array}		store.Role
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [get]
+func (app *application) getRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.List(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateRole godoc
+//
+//	@Summary		Create role
+//	@Description	create a role without permissions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateRolePayload	true	"Role"
+//	@Success		201		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [post]
+func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	role := &store.Role{
+		Name:        payload.Name,
+		Level:       payload.Level,
+		Description: payload.Description,
+	}
+
+	if err := app.store.Roles.Create(r.Context(), role); err != nil {
+		switch err {
+		case store.ErrorAlreadyExists:
+			app.conflictErrorResponse(w, r, errors.New("a role with this name already exists"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, role); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteRole godoc
+//
+//	@Summary		Delete role
+//	@Description	delete a role no user has. The seeded roles cannot be deleted
+//	@Tags			admin
+//	@Produce		json
+//	@Param			roleName	path	string	true	"Role name"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleName} [delete]
+func (app *application) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "roleName")
+	if seededRoles[name] {
+		app.badRequestErrorResponse(w, r, errors.New("built-in roles cannot be deleted"))
+		return
+	}
+
+	if err := app.store.Roles.Delete(r.Context(), name); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		case store.ErrorRoleInUse:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("role deleted", "role", name, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPermissions godoc
+//
+//	@Summary		List permissions
+//	@Description	list the permissions roles can be granted
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{array}		store.Permission
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/permissions [get]
+func (app *application) getPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := app.store.Roles.ListPermissions(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, permissions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GrantPermission godoc
+//
+//	@Summary		Grant permission
+//	@Description	grant a permission to a role
+//	@Tags			admin
+//	@Produce		json
+//	@Param			roleName	path	string	true	"Role name"
+//	@Param			permission	path	string	true	"Permission name"
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleName}/permissions/{permission} [put]
+func (app *application) grantPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "roleName")
+	permission := chi.URLParam(r, "permission")
+
+	if err := app.store.Roles.Grant(r.Context(), name, permission); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, errors.New("role or permission not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("permission granted", "role", name, "permission", permission, "by", getUserFromContext(r).ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokePermission godoc
+//
+//	@Summary		Revoke permission
+//	@Description	take a permission away from a role
+//	@Tags			admin
+//	@Produce		json
+//	@Param			roleName	path	string	true	"Role name"
+//	@Param			permission	path	string	true	"Permission name"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleName}/permissions/{permission} [delete]
+func (app *application) revokePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "roleName")
+	permission := chi.URLParam(r, "permission")
+	user := getUserFromContext(r)
+
+	// nobody would be left to give it back
+	if permission == permRolesManage && name == user.Role.Name {
+		app.badRequestErrorResponse(w, r, errors.New("cannot revoke role management from your own role"))
+		return
+	}
+
+	if err := app.store.Roles.Revoke(r.Context(), name, permission); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("permission revoked", "role", name, "permission", permission, "by", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	maxUserAgentLength     = 512
+	sessionCleanupInterval = time.Hour
+)
+
+type SessionView struct {
+	store.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions godoc
+//
+//	@Summary		List sessions
+//	@Description	list the devices the current user is logged in on
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		SessionView
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	sessions, err := app.store.Sessions.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	currentId, _ := getClaimsFromContext(r)["sid"].(string)
+
+	views := make([]SessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, SessionView{Session: session, Current: session.ID == currentId})
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, views); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteSession godoc
+//
+//	@Summary		End session
+//	@Description	log the current user out of a device. Its tokens stop working immediately
+//	@Tags			users
+//	@Produce		json
+//	@Param			sessionId	path	string	true	"Session ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionId} [delete]
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionId := chi.URLParam(r, "sessionId")
+	if _, err := uuid.Parse(sessionId); err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("invalid session ID"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Sessions.Delete(r.Context(), sessionId, user.ID); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) newSession(r *http.Request, userId int64) *store.Session {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return &store.Session{
+		ID:        uuid.New().String(),
+		UserId:    userId,
+		UserAgent: userAgent,
+		IP:        clientIP(r),
+	}
+}
+
+// runSessionCleanup periodically deletes the sessions whose refresh tokens
+// have all expired or been revoked, until ctx is cancelled.
+func (app *application) runSessionCleanup(ctx context.Context) {
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := app.store.Sessions.DeleteExpired(ctx)
+		if err != nil {
+			app.logger.Errorw("failed to delete expired sessions", "error", err.Error())
+		} else if deleted > 0 {
+			app.logger.Infow("deleted expired sessions", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
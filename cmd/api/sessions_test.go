@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessions(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{token: tokenConfig{exp: time.Hour, aud: "test", iss: "test"}},
+	})
+	// the test authenticator ignores the claims, the session id has to be signed
+	app.authenticator = auth.NewJWTAuthenticator("example", "test", "test")
+	mux := app.mount()
+
+	const sessionId = "7f1c6e0a-4c4e-4d8a-9a57-2b7f1b0e2c11"
+
+	tokens, err := app.newAuthTokens(&store.User{ID: 1}, sessionId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should list sessions and flag the current one", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockSessionStore := new(store.MockSessionStore)
+		app.store.Users = mockUserStore
+		app.store.Sessions = mockSessionStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockSessionStore.On("Touch", mock.Anything, sessionId, int64(1)).Return(nil).Once()
+		mockSessionStore.On("GetByUser", mock.Anything, int64(1)).Return([]store.Session{
+			{ID: sessionId, UserId: 1, UserAgent: "curl"},
+			{ID: "0d3f5c8e-2b7a-4f61-8c1e-5a9b3d2e7f40", UserId: 1, UserAgent: "firefox"},
+		}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/me/sessions", tokens.AccessToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data []SessionView `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data) != 2 || !body.Data[0].Current || body.Data[1].Current {
+			t.Errorf("unexpected sessions %+v", body.Data)
+		}
+		mockSessionStore.AssertExpectations(t)
+	})
+
+	t.Run("should end another session", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockSessionStore := new(store.MockSessionStore)
+		app.store.Users = mockUserStore
+		app.store.Sessions = mockSessionStore
+
+		otherId := "0d3f5c8e-2b7a-4f61-8c1e-5a9b3d2e7f40"
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockSessionStore.On("Touch", mock.Anything, sessionId, int64(1)).Return(nil).Once()
+		mockSessionStore.On("Delete", mock.Anything, otherId, int64(1)).Return(nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me/sessions/"+otherId, tokens.AccessToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockSessionStore.AssertExpectations(t)
+	})
+
+	t.Run("should return not found for a session of another user", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockSessionStore := new(store.MockSessionStore)
+		app.store.Users = mockUserStore
+		app.store.Sessions = mockSessionStore
+
+		otherId := "0d3f5c8e-2b7a-4f61-8c1e-5a9b3d2e7f40"
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockSessionStore.On("Touch", mock.Anything, sessionId, int64(1)).Return(nil).Once()
+		mockSessionStore.On("Delete", mock.Anything, otherId, int64(1)).Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodDelete, "/v1/users/me/sessions/"+otherId, tokens.AccessToken, nil), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should reject tokens of an ended session", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockSessionStore := new(store.MockSessionStore)
+		app.store.Users = mockUserStore
+		app.store.Sessions = mockSessionStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockSessionStore.On("Touch", mock.Anything, sessionId, int64(1)).Return(store.ErrorNotFound).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users/me/sessions", tokens.AccessToken, nil), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockSessionStore.AssertNotCalled(t, "GetByUser", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSessionCleanup(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mockSessionStore := new(store.MockSessionStore)
+	app.store.Sessions = mockSessionStore
+
+	t.Run("should delete expired sessions until stopped", func(t *testing.T) {
+		mockSessionStore.On("DeleteExpired", mock.Anything).Return(int64(2), nil).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		app.runSessionCleanup(ctx)
+
+		mockSessionStore.AssertExpectations(t)
+	})
+}
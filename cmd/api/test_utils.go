@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"social/internal/auth"
+	"social/internal/lockout"
 	mailer "social/internal/mailer"
 	"social/internal/ratelimiter"
 	"social/internal/store"
@@ -11,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +25,21 @@ func newTestApplication(t *testing.T, cfg config) *application {
 	logger := zap.NewNop().Sugar()
 	mockStore := store.NewMockStore()
 	mockCacheStore := cache.NewMockStore()
+
+	// every authenticated request checks the revocation list
+	mockStore.RevokedTokens.(*store.MockRevokedTokenStore).On("IsRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	mockCacheStore.RevokedTokens.(*cache.MockRevokedTokensStore).On("IsRevoked", mock.Anything).Return(false, nil)
+
+	// and every login, logout and refresh keeps its session up to date
+	mockSessionStore := mockStore.Sessions.(*store.MockSessionStore)
+	mockSessionStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mockSessionStore.On("Touch", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockSessionStore.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockSessionStore.On("DeleteAllByUser", mock.Anything, mock.Anything).Return(nil)
+
+	// and every post view checks whether the viewer and the author blocked each other
+	mockStore.Blocks.(*store.MockBlockStore).On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
 	mockMailer := new(mailer.MockMailer)
 	testAuth := &auth.TestAuthenticator{}
 
@@ -35,17 +55,72 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	if cfg.invitationRateLimiter.RequestsPerTimeFrame == 0 {
+		cfg.invitationRateLimiter.RequestsPerTimeFrame = 3
+	}
+	if cfg.invitationRateLimiter.TimeFrame == 0 {
+		cfg.invitationRateLimiter.TimeFrame = time.Hour
+	}
+
+	invitationLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.invitationRateLimiter.RequestsPerTimeFrame,
+		cfg.invitationRateLimiter.TimeFrame,
+	)
+
+	if cfg.loginLockout.account.Threshold == 0 {
+		cfg.loginLockout.account = lockout.Config{Threshold: 5, BaseDuration: time.Minute, MaxDuration: time.Hour, Window: time.Hour}
+	}
+	if cfg.loginLockout.ip.Threshold == 0 {
+		cfg.loginLockout.ip = lockout.Config{Threshold: 20, BaseDuration: time.Minute, MaxDuration: time.Hour, Window: time.Hour}
+	}
+
 	return &application{
-		config:        cfg,
-		logger:        logger,
-		store:         mockStore,
-		cacheStore:    mockCacheStore,
-		mailer:        mockMailer,
-		authenticator: testAuth,
-		rateLimiter:   rateLimiter,
+		config:            cfg,
+		logger:            logger,
+		store:             mockStore,
+		cacheStore:        mockCacheStore,
+		mailer:            mockMailer,
+		authenticator:     testAuth,
+		rateLimiter:       rateLimiter,
+		invitationLimiter: invitationLimiter,
+		accountLockout:    lockout.NewMemoryTracker(cfg.loginLockout.account),
+		ipLockout:         lockout.NewMemoryTracker(cfg.loginLockout.ip),
 	}
 }
 
+// newJSONRequest builds a request with the payload as its JSON body, or with
+// no body when the payload is nil.
+func newJSONRequest(t *testing.T, method, url string, payload any) *http.Request {
+	t.Helper()
+
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// newAuthedRequest builds a JSON request signed in with the access token.
+func newAuthedRequest(t *testing.T, method, url, token string, payload any) *http.Request {
+	t.Helper()
+
+	req := newJSONRequest(t, method, url, payload)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
 func executeRequest(req *http.Request, mux http.Handler) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	mux.ServeHTTP(rr, req)
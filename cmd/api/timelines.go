@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"social/internal/store"
+)
+
+// fanOutPost pushes a new post to the timelines of its author and the
+// author's followers. Authors above the celebrity threshold only get it in
+// their own timeline, and their posts are read with the feed instead.
+func (app *application) fanOutPost(ctx context.Context, post *store.Post) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	counts, err := app.store.Users.GetCounts(ctx, post.UserId)
+	if err != nil {
+		return err
+	}
+
+	userIds := []int64{post.UserId}
+	if counts.Followers > int64(app.config.timelines.celebrityThreshold) {
+		// an author who drops below the threshold stays a celebrity until
+		// the timelines are rebuilt, since older posts were never pushed
+		if err := app.cacheStore.Timelines.AddCelebrity(ctx, post.UserId); err != nil {
+			return err
+		}
+	} else {
+		followers, err := app.store.Followers.GetFollowerIds(ctx, post.UserId)
+		if err != nil {
+			return err
+		}
+		userIds = append(userIds, followers...)
+	}
+
+	return app.cacheStore.Timelines.Push(ctx, post.TimelineEntry(), userIds, app.config.timelines.size)
+}
+
+// getHomeFeed reads the home feed through the user's timeline when Redis is
+// enabled. A user without a timeline gets it straight from Postgres.
+func (app *application) getHomeFeed(ctx context.Context, userId int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Posts.GetUserFeed(ctx, userId, fq)
+	}
+
+	timeline, err := app.cacheStore.Timelines.Get(ctx, userId)
+	if err != nil {
+		app.logger.Errorw("failed to read timeline", "user_id", userId, "error", err.Error())
+		return app.store.Posts.GetUserFeed(ctx, userId, fq)
+	}
+	if len(timeline) == 0 {
+		return app.store.Posts.GetUserFeed(ctx, userId, fq)
+	}
+
+	celebrities, err := app.cacheStore.Timelines.GetCelebrities(ctx)
+	if err != nil {
+		app.logger.Errorw("failed to read celebrities", "error", err.Error())
+		return app.store.Posts.GetUserFeed(ctx, userId, fq)
+	}
+
+	return app.store.Posts.GetTimelineFeed(ctx, userId, timeline, celebrities, fq)
+}
+
+// invalidateTimeline drops the timeline of a user who started following
+// someone, whose earlier posts it is missing. The feed is read from Postgres
+// until a new post starts the timeline again.
+func (app *application) invalidateTimeline(ctx context.Context, userId int64) {
+	if app.config.redisCfg.enabled {
+		app.cacheStore.Timelines.Delete(ctx, userId)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"social/internal/store"
+	"social/internal/store/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFanOutPost(t *testing.T) {
+	app := newTestApplication(t, config{
+		redisCfg:  redisConfig{enabled: true},
+		timelines: timelineConfig{size: 800, celebrityThreshold: 2},
+	})
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := &store.Post{ID: 7, UserId: 1, CreatedAt: createdAt.Format(time.RFC3339Nano)}
+	entry := store.TimelineEntry{PostId: 7, CreatedAt: createdAt}
+
+	setup := func(t *testing.T, followers int64) (*store.MockFollowerStore, *cache.MockTimelinesStore) {
+		mockUserStore := new(store.MockUserStore)
+		mockFollowerStore := new(store.MockFollowerStore)
+		mockTimelines := new(cache.MockTimelinesStore)
+		app.store.Users = mockUserStore
+		app.store.Followers = mockFollowerStore
+		app.cacheStore.Timelines = mockTimelines
+
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{Followers: followers}, nil)
+		return mockFollowerStore, mockTimelines
+	}
+
+	t.Run("should push the post to the author and the followers", func(t *testing.T) {
+		mockFollowerStore, mockTimelines := setup(t, 2)
+		mockFollowerStore.On("GetFollowerIds", mock.Anything, int64(1)).Return([]int64{2, 3}, nil).Once()
+		mockTimelines.On("Push", entry, []int64{1, 2, 3}, 800).Return(nil).Once()
+
+		if err := app.fanOutPost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+
+		mockFollowerStore.AssertExpectations(t)
+		mockTimelines.AssertExpectations(t)
+		mockTimelines.AssertNotCalled(t, "AddCelebrity", mock.Anything)
+	})
+
+	t.Run("should leave the followers of celebrities to read it with the feed", func(t *testing.T) {
+		mockFollowerStore, mockTimelines := setup(t, 3)
+		mockTimelines.On("AddCelebrity", int64(1)).Return(nil).Once()
+		mockTimelines.On("Push", entry, []int64{1}, 800).Return(nil).Once()
+
+		if err := app.fanOutPost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+
+		mockTimelines.AssertExpectations(t)
+		mockFollowerStore.AssertNotCalled(t, "GetFollowerIds", mock.Anything, mock.Anything)
+	})
+}
+
+func TestHomeFeedTimeline(t *testing.T) {
+	app := newTestApplication(t, config{redisCfg: redisConfig{enabled: true}})
+	ctx := context.Background()
+	fq := store.PaginatedFeedQuery{Limit: 10, Sort: "desc", Tags: []string{}}
+
+	t.Run("should read the feed through the timeline", func(t *testing.T) {
+		mockPostStore := new(store.MockPostStore)
+		mockTimelines := new(cache.MockTimelinesStore)
+		app.store.Posts = mockPostStore
+		app.cacheStore.Timelines = mockTimelines
+
+		timeline := []store.TimelineEntry{{PostId: 9, CreatedAt: time.Now()}, {PostId: 4, CreatedAt: time.Now().Add(-time.Hour)}}
+		feed := []store.PostWithMetadata{{Post: store.Post{ID: 9}}, {Post: store.Post{ID: 4}}}
+
+		mockTimelines.On("Get", int64(1)).Return(timeline, nil)
+		mockTimelines.On("GetCelebrities").Return([]int64{5}, nil)
+		mockPostStore.On("GetTimelineFeed", mock.Anything, int64(1), timeline, []int64{5}, fq).Return(feed, nil).Once()
+
+		posts, err := app.getHomeFeed(ctx, 1, fq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 {
+			t.Errorf("expected 2 posts, got %d", len(posts))
+		}
+
+		mockPostStore.AssertExpectations(t)
+		mockPostStore.AssertNotCalled(t, "GetUserFeed", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should read the feed from the posts without a timeline", func(t *testing.T) {
+		mockPostStore := new(store.MockPostStore)
+		mockTimelines := new(cache.MockTimelinesStore)
+		app.store.Posts = mockPostStore
+		app.cacheStore.Timelines = mockTimelines
+
+		mockTimelines.On("Get", int64(1)).Return([]store.TimelineEntry{}, nil)
+		mockPostStore.On("GetUserFeed", mock.Anything, int64(1), fq).Return([]store.PostWithMetadata{}, nil).Once()
+
+		if _, err := app.getHomeFeed(ctx, 1, fq); err != nil {
+			t.Fatal(err)
+		}
+
+		mockPostStore.AssertExpectations(t)
+		mockTimelines.AssertNotCalled(t, "GetCelebrities")
+	})
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const recoveryCodesCount = 10
+
+// developmentTwoFactorKey encrypts TOTP secrets outside of production when no
+// key is configured, so that enrollments survive restarts.
+const developmentTwoFactorKey = "development"
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorChallenge struct {
+	Challenge string `json:"challenge"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type VerifyTwoFactorPayload struct {
+	Challenge string `json:"challenge" validate:"required,max=255"`
+	Code      string `json:"code" validate:"required,max=20"`
+}
+
+// EnrollTwoFactor godoc
+//
+//	@Summary		Enroll two-factor authentication
+//	@Description	generate a TOTP secret for the current user, which has to be confirmed with a code
+//	@Tags			users
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sealed, err := auth.SealTOTPSecret(app.config.auth.twoFactor.secretKey, secret)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.SetSecret(r.Context(), user.ID, sealed); err != nil {
+		switch err {
+		case store.ErrorAlreadyExists:
+			app.conflictErrorResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(app.config.auth.twoFactor.issuer, user.Email, secret),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmTwoFactor godoc
+//
+//	@Summary		Confirm two-factor authentication
+//	@Description	enable two-factor authentication with a code from the enrolled secret and return one-time recovery codes
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa/confirm [post]
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	if user.TwoFactorEnabled {
+		app.conflictErrorResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := app.getTOTPSecret(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.badRequestErrorResponse(w, r, errors.New("two-factor enrollment has not been started"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestErrorResponse(w, r, errInvalidTwoFactorCode)
+		return
+	}
+
+	if err := app.store.TwoFactor.UseTOTPStep(ctx, user.ID, step); err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.badRequestErrorResponse(w, r, errInvalidTwoFactorCode)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	if err := app.store.TwoFactor.Enable(ctx, user.ID, hashes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, &RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DisableTwoFactor godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	disable two-factor authentication with a TOTP or recovery code
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	TwoFactorCodePayload	true	"TOTP or recovery code"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [delete]
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	if !user.TwoFactorEnabled {
+		app.badRequestErrorResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	valid, err := app.verifySecondFactor(ctx, user.ID, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !valid {
+		app.badRequestErrorResponse(w, r, errInvalidTwoFactorCode)
+		return
+	}
+
+	if err := app.store.TwoFactor.Disable(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createTwoFactorChallenge answers a login with a correct password by a
+// short lived challenge, which is exchanged for tokens together with a code.
+func (app *application) createTwoFactorChallenge(w http.ResponseWriter, r *http.Request, user *store.User) {
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	exp := app.config.auth.twoFactor.challengeExp
+	if err := app.store.TwoFactor.CreateChallenge(r.Context(), user.ID, hashToken, exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	challenge := &TwoFactorChallenge{
+		Challenge: plainToken,
+		ExpiresIn: int64(exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// VerifyTwoFactor godoc
+//
+//	@Summary		Complete a two-factor login
+//	@Description	exchange a login challenge and a TOTP or recovery code for an access token and a refresh token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyTwoFactorPayload	true	"Challenge and code"
+//	@Success		201		{object}	AuthTokens
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Router			/authentication/token/2fa [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	userId, err := app.store.TwoFactor.ConsumeChallenge(ctx, payload.Challenge)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.unAuthorizedErrorResponse(w, r, errors.New("invalid or expired challenge"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetById(ctx, userId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.IsBanned() {
+		app.accountBannedResponse(w, r, user)
+		return
+	}
+
+	valid, err := app.verifySecondFactor(ctx, user.ID, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !valid {
+		app.unAuthorizedErrorResponse(w, r, errInvalidTwoFactorCode)
+		return
+	}
+
+	app.issueAuthTokens(w, r, user)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, which is consumed. A TOTP code is only accepted once.
+func (app *application) verifySecondFactor(ctx context.Context, userId int64, code string) (bool, error) {
+	secret, err := app.getTOTPSecret(ctx, userId)
+	if err != nil && err != store.ErrorNotFound {
+		return false, err
+	}
+
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now()); secret != "" && ok {
+		err := app.store.TwoFactor.UseTOTPStep(ctx, userId, step)
+		switch err {
+		case nil:
+			return true, nil
+		case store.ErrorNotFound:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	err = app.store.TwoFactor.UseRecoveryCode(ctx, userId, auth.HashRecoveryCode(code))
+	switch err {
+	case nil:
+		return true, nil
+	case store.ErrorNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// getTOTPSecret returns the decrypted TOTP secret of the user.
+func (app *application) getTOTPSecret(ctx context.Context, userId int64) (string, error) {
+	sealed, err := app.store.TwoFactor.GetSecret(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	return auth.OpenTOTPSecret(app.config.auth.twoFactor.secretKey, sealed)
+}
+
+// meetsTwoFactorPolicy reports whether the user may use the privileges of
+// their role. Roles granted permTwoFactorRequired need two-factor
+// authentication enabled.
+func (app *application) meetsTwoFactorPolicy(ctx context.Context, user *store.User) (bool, error) {
+	if user.TwoFactorEnabled {
+		return true, nil
+	}
+
+	required, err := app.store.Roles.HasPermission(ctx, user.Role.Name, permTwoFactorRequired)
+	if err != nil {
+		return false, err
+	}
+
+	return !required, nil
+}
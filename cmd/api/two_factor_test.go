@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+// sealTestTOTPSecret returns testTOTPSecret the way the app stores it.
+func sealTestTOTPSecret(t *testing.T, app *application) string {
+	t.Helper()
+
+	sealed, err := auth.SealTOTPSecret(app.config.auth.twoFactor.secretKey, testTOTPSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func TestEnrollTwoFactor(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should return a secret and an otpauth uri", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+
+		var stored string
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, Email: "test@test.com"}, nil)
+		mockTwoFactorStore.On("SetSecret", mock.Anything, int64(1), mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.String(2)
+		}).Return(nil).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/users/me/2fa", testToken, nil)
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var body struct {
+			Data TwoFactorEnrollment `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.Secret == "" || body.Data.URI == "" {
+			t.Errorf("expected a secret and an uri, got %+v", body.Data)
+		}
+		if stored == body.Data.Secret {
+			t.Errorf("expected the secret to be stored encrypted")
+		}
+		if opened, err := auth.OpenTOTPSecret(app.config.auth.twoFactor.secretKey, stored); err != nil || opened != body.Data.Secret {
+			t.Errorf("expected the stored secret to open to %q, got %q (%v)", body.Data.Secret, opened, err)
+		}
+		mockTwoFactorStore.AssertExpectations(t)
+	})
+
+	t.Run("should not enroll twice", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, TwoFactorEnabled: true}, nil)
+		mockTwoFactorStore.On("SetSecret", mock.Anything, int64(1), mock.Anything).Return(store.ErrorAlreadyExists).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/users/me/2fa", testToken, nil)
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+	})
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should enable two-factor and return recovery codes", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockTwoFactorStore.On("GetSecret", mock.Anything, int64(1)).Return(sealTestTOTPSecret(t, app), nil)
+		mockTwoFactorStore.On("UseTOTPStep", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+		mockTwoFactorStore.On("Enable", mock.Anything, int64(1), mock.MatchedBy(func(codes []string) bool {
+			return len(codes) == recoveryCodesCount
+		})).Return(nil).Once()
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/users/me/2fa/confirm", testToken, TwoFactorCodePayload{Code: code})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data RecoveryCodes `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data.RecoveryCodes) != recoveryCodesCount {
+			t.Errorf("expected %d recovery codes, got %d", recoveryCodesCount, len(body.Data.RecoveryCodes))
+		}
+		mockTwoFactorStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject an invalid code", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockTwoFactorStore.On("GetSecret", mock.Anything, int64(1)).Return(sealTestTOTPSecret(t, app), nil)
+
+		req := newAuthedRequest(t, http.MethodPost, "/v1/users/me/2fa/confirm", testToken, TwoFactorCodePayload{Code: "abcdef"})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockTwoFactorStore.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	setup := func(t *testing.T) (*store.MockTwoFactorStore, *store.MockRefreshTokenStore) {
+		mockUserStore := new(store.MockUserStore)
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		mockRefreshTokenStore := new(store.MockRefreshTokenStore)
+		app.store.Users = mockUserStore
+		app.store.TwoFactor = mockTwoFactorStore
+		app.store.RefreshTokens = mockRefreshTokenStore
+
+		mockTwoFactorStore.On("ConsumeChallenge", mock.Anything, "challenge").Return(int64(1), nil).Once()
+		mockTwoFactorStore.On("GetSecret", mock.Anything, int64(1)).Return(sealTestTOTPSecret(t, app), nil)
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1, TwoFactorEnabled: true}, nil)
+
+		return mockTwoFactorStore, mockRefreshTokenStore
+	}
+
+	t.Run("should issue tokens for a valid totp code", func(t *testing.T) {
+		mockTwoFactorStore, mockRefreshTokenStore := setup(t)
+		mockTwoFactorStore.On("UseTOTPStep", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token/2fa", VerifyTwoFactorPayload{Challenge: "challenge", Code: code})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		mockRefreshTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject a totp code that was used before", func(t *testing.T) {
+		mockTwoFactorStore, mockRefreshTokenStore := setup(t)
+		mockTwoFactorStore.On("UseTOTPStep", mock.Anything, int64(1), mock.Anything).Return(store.ErrorNotFound).Once()
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token/2fa", VerifyTwoFactorPayload{Challenge: "challenge", Code: code})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockTwoFactorStore.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything, mock.Anything)
+		mockRefreshTokenStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should issue tokens for an unused recovery code", func(t *testing.T) {
+		mockTwoFactorStore, mockRefreshTokenStore := setup(t)
+		mockTwoFactorStore.On("UseRecoveryCode", mock.Anything, int64(1), auth.HashRecoveryCode("abcde-fghij")).Return(nil).Once()
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token/2fa", VerifyTwoFactorPayload{Challenge: "challenge", Code: "ABCDE FGHIJ"})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		mockTwoFactorStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject an invalid code", func(t *testing.T) {
+		mockTwoFactorStore, mockRefreshTokenStore := setup(t)
+		mockTwoFactorStore.On("UseRecoveryCode", mock.Anything, int64(1), mock.Anything).Return(store.ErrorNotFound).Once()
+
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token/2fa", VerifyTwoFactorPayload{Challenge: "challenge", Code: "000000"})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockRefreshTokenStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject an unknown challenge", func(t *testing.T) {
+		mockTwoFactorStore := new(store.MockTwoFactorStore)
+		app.store.TwoFactor = mockTwoFactorStore
+
+		mockTwoFactorStore.On("ConsumeChallenge", mock.Anything, "expired").Return(int64(0), store.ErrorNotFound).Once()
+
+		req := newJSONRequest(t, http.MethodPost, "/v1/authentication/token/2fa", VerifyTwoFactorPayload{Challenge: "expired", Code: "000000"})
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestTwoFactorPolicy(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := store.Post{ID: 1, UserId: 2}
+
+	tests := []struct {
+		name             string
+		role             store.Role
+		required         bool
+		twoFactorEnabled bool
+		expected         int
+	}{
+		{"should forbid an admin without two-factor", store.Role{Name: "admin"}, true, false, http.StatusForbidden},
+		{"should allow an admin with two-factor", store.Role{Name: "admin"}, true, true, http.StatusNoContent},
+		{"should forbid a custom role that requires two-factor", store.Role{Name: "curator"}, true, false, http.StatusForbidden},
+		{"should allow a custom role that does not require two-factor", store.Role{Name: "curator"}, false, false, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserStore := new(store.MockUserStore)
+			mockPostsStore := new(store.MockPostStore)
+			mockRoleStore := new(store.MockRolesStore)
+			app.store.Users = mockUserStore
+			app.store.Posts = mockPostsStore
+			app.store.Roles = mockRoleStore
+
+			user := &store.User{ID: 1, Role: tt.role, TwoFactorEnabled: tt.twoFactorEnabled}
+			mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
+			mockPostsStore.On("GetById", mock.Anything, int64(1)).Return(post, nil)
+			mockPostsStore.On("Delete", mock.Anything, int64(1)).Return(nil)
+			mockRoleStore.On("HasPermission", mock.Anything, tt.role.Name, permPostDeleteAny).Return(true, nil)
+			mockRoleStore.On("HasPermission", mock.Anything, tt.role.Name, permTwoFactorRequired).Return(tt.required, nil)
+
+			req := newAuthedRequest(t, http.MethodDelete, "/v1/posts/1", testToken, nil)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, tt.expected, rr.Code)
+		})
+	}
+}
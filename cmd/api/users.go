@@ -13,15 +13,29 @@ type userKey string
 
 const userCtxKey userKey = "user"
 
+// UserProfile is the public view of a user. It leaves out the email and the
+// account state.
+type UserProfile struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Bio       string `json:"bio"`
+	AvatarURL string `json:"avatar_url"`
+	Website   string `json:"website"`
+	Location  string `json:"location"`
+	IsPrivate bool   `json:"is_private"`
+	CreatedAt string `json:"created_at"`
+	store.UserCounts
+}
+
 // GETUSER godoc
 //
 //	@Summary		Get user by ID
-//	@Description	get user by ID
+//	@Description	get the public profile of a user with their follower, following and post counts
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userId	path		int	true	"User ID"
-//	@Success		200		{object}	store.User
+//	@Success		200		{object}	UserProfile
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
@@ -35,7 +49,9 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.getUserWithRedis(r.Context(), userID)
+	ctx := r.Context()
+
+	user, err := app.getUserWithRedis(ctx, userID)
 	if err != nil {
 		switch err {
 		case store.ErrorNotFound:
@@ -47,7 +63,70 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	counts, err := app.store.Users.GetCounts(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	profile := UserProfile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Bio:        user.Bio,
+		AvatarURL:  user.AvatarURL,
+		Website:    user.Website,
+		Location:   user.Location,
+		IsPrivate:  user.IsPrivate,
+		CreatedAt:  user.CreatedAt,
+		UserCounts: *counts,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SearchUsers godoc
+//
+//	@Summary		Search users
+//	@Description	search active users by username, best match first. The autocomplete mode matches username prefixes and lists followed users first
+//	@Tags			users
+//	@Produce		json
+//	@Param			search	query		string	true	"Username to look for"
+//	@Param			mode	query		string	false	"Either 'full' or 'autocomplete'"	default(full)
+//	@Param			limit	query		int		false	"Users per page"					default(20)
+//	@Param			offset	query		int		false	"Offset for pagination"				default(0)
+//	@Success		200		{array}		store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//	@Router			/users [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.UserSearchQuery{
+		Mode:  "full",
+		Limit: 20,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), getUserFromContext(r).ID, sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -55,13 +134,16 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follow user
-//	@Description	follow user
+//	@Description	follow user. Following a private account sends a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userId	path	int	true	"User ID"
+//	@Param			userId	path		int		true	"User ID"
+//	@Success		202		{string}	string	"Follow requested"
 //	@Success		204
 //	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
 //	@Failure		409	{object}	error
 //	@Failure		500	{object}	error
 //
@@ -76,18 +158,47 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if followedId == followerUser.ID {
+		app.badRequestErrorResponse(w, r, errors.New("users cannot follow themselves"))
+		return
+	}
+
+	followed, err := app.getUserWithRedis(ctx, followedId)
+	if err != nil {
+		switch err {
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if followed.IsPrivate {
+		app.requestFollow(w, r, followerUser.ID, followedId)
+		return
+	}
+
 	err = app.store.Followers.FollowUser(ctx, followerUser.ID, followedId)
 	if err != nil {
 		switch err {
 		case store.ErrorAlreadyExists:
 			app.conflictErrorResponse(w, r, err)
 			return
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
+			return
+		case store.ErrorBlocked:
+			app.forbiddenErrorResponse(w, r, err)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
 		}
 	}
 
+	app.invalidateTimeline(ctx, followerUser.ID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -97,7 +208,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser godoc
 //
 //	@Summary		Unfollow user
-//	@Description	unfollow user
+//	@Description	unfollow user or withdraw the follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -121,8 +232,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	err = app.store.Followers.UnfollowUser(ctx, followerUser.ID, followedId)
 	if err != nil {
 		switch err {
-		case store.ErrorAlreadyExists:
-			app.conflictErrorResponse(w, r, err)
+		case store.ErrorNotFound:
+			app.notFoundErrorResponse(w, r, err)
 			return
 		default:
 			app.internalServerError(w, r, err)
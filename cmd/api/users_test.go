@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
 	"social/internal/store"
 	"social/internal/store/cache"
@@ -49,6 +50,7 @@ func TestGetUser(t *testing.T) {
 		}
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{}, nil)
 		mockCacheStore.On("Get", int64(1)).Return(nil, nil).Twice()
 		mockCacheStore.On("Set", mock.Anything).Return(nil)
 
@@ -80,6 +82,7 @@ func TestGetUser(t *testing.T) {
 		}
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{}, nil)
 		mockCacheStore.On("Get", int64(42)).Return(nil, nil)
 		mockCacheStore.On("Get", int64(1)).Return(nil, nil)
 		mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
@@ -123,6 +126,7 @@ func TestGetUser(t *testing.T) {
 		}
 
 		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(user, nil)
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{}, nil)
 
 		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
 		if err != nil {
@@ -139,4 +143,91 @@ func TestGetUser(t *testing.T) {
 
 		mockCacheStore.Calls = nil
 	})
+	t.Run("should hide the email from the public profile", func(t *testing.T) {
+		app := newTestApplication(t, config{})
+		mux := app.mount()
+
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{
+			ID:       1,
+			Username: "testUser",
+			Email:    "test@test.com",
+			Bio:      "hello",
+		}, nil)
+		mockUserStore.On("GetCounts", mock.Anything, int64(1)).Return(&store.UserCounts{}, nil)
+
+		req := newAuthedRequest(t, http.MethodGet, "/v1/users/1", testToken, nil)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := body.Data["email"]; ok {
+			t.Error("expected the email to be hidden")
+		}
+		if body.Data["bio"] != "hello" {
+			t.Errorf("expected the bio, got %v", body.Data["bio"])
+		}
+		if _, ok := body.Data["followers_count"]; !ok {
+			t.Error("expected the follower count")
+		}
+	})
+}
+
+func TestSearchUsers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should require a search term", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users?search=%20", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		mockUserStore.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should search in autocomplete mode", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+		mockUserStore.On("Search", mock.Anything, int64(1), store.UserSearchQuery{
+			Search: "ali",
+			Mode:   "autocomplete",
+			Limit:  5,
+		}).Return([]store.UserSearchResult{{ID: 2, Username: "alice"}}, nil).Once()
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users?search=ali&mode=autocomplete&limit=5", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockUserStore.AssertExpectations(t)
+	})
+
+	t.Run("should reject an unknown mode", func(t *testing.T) {
+		mockUserStore := new(store.MockUserStore)
+		app.store.Users = mockUserStore
+
+		mockUserStore.On("GetById", mock.Anything, int64(1)).Return(&store.User{ID: 1}, nil)
+
+		rr := executeRequest(newAuthedRequest(t, http.MethodGet, "/v1/users?search=ali&mode=exact", testToken, nil), mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
 }
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS revoked_tokens;
+
+ALTER TABLE users
+DROP COLUMN token_version;
\ No newline at end of file
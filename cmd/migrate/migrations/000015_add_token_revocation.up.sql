@@ -0,0 +1,9 @@
+ALTER TABLE users
+ADD COLUMN token_version int NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti uuid PRIMARY KEY,
+    expiry timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expiry ON revoked_tokens (expiry);
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS users_two_factor_challenges;
+
+DROP TABLE IF EXISTS users_recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS two_factor_enabled,
+DROP COLUMN IF EXISTS totp_last_step,
+DROP COLUMN IF EXISTS totp_secret;
\ No newline at end of file
@@ -0,0 +1,22 @@
+ALTER TABLE users
+ADD COLUMN totp_secret text,
+ADD COLUMN totp_last_step bigint,
+ADD COLUMN two_factor_enabled boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS users_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code bytea NOT NULL,
+    used_at timestamp with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (user_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS users_two_factor_challenges (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    expiry timestamp with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
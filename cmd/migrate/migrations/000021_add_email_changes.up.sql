@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users_email_changes (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    email citext NOT NULL,
+    expiry timestamp with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
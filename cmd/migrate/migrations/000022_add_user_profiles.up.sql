@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN bio text NOT NULL DEFAULT '',
+ADD COLUMN avatar_url text NOT NULL DEFAULT '',
+ADD COLUMN website text NOT NULL DEFAULT '',
+ADD COLUMN location varchar(100) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
+CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id);
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+
+ALTER TABLE followers
+DROP CONSTRAINT IF EXISTS followers_not_self;
+
+CREATE TEMPORARY TABLE followers_swapped AS
+SELECT follower_id AS user_id, user_id AS follower_id, created_at FROM followers;
+
+DELETE FROM followers;
+
+INSERT INTO followers (user_id, follower_id, created_at)
+SELECT user_id, follower_id, created_at FROM followers_swapped;
+
+DROP TABLE followers_swapped;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- rows used to be written with user_id and follower_id swapped
+CREATE TEMPORARY TABLE followers_swapped AS
+SELECT follower_id AS user_id, user_id AS follower_id, created_at FROM followers;
+
+DELETE FROM followers;
+
+INSERT INTO followers (user_id, follower_id, created_at)
+SELECT user_id, follower_id, created_at FROM followers_swapped
+WHERE user_id <> follower_id;
+
+DROP TABLE followers_swapped;
+
+ALTER TABLE followers
+ADD CONSTRAINT followers_not_self CHECK (user_id <> follower_id);
+
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at, follower_id);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at, user_id);
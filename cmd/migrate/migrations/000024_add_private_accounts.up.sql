@@ -0,0 +1,15 @@
+ALTER TABLE users
+ADD COLUMN is_private boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS follow_requests (
+    user_id bigint NOT NULL,
+    requester_id bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, requester_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (user_id <> requester_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_created_at ON follow_requests (user_id, created_at, requester_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id bigint NOT NULL,
+    blocked_id bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id, blocker_id);
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocker_id_created_at ON user_blocks (blocker_id, created_at, blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id bigint NOT NULL,
+    muted_id bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (muter_id <> muted_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_mutes_muter_id_created_at ON user_mutes (muter_id, created_at, muted_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspension_reason,
+DROP COLUMN IF EXISTS suspended_until;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN suspended_until timestamp with time zone,
+ADD COLUMN suspension_reason text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_users_suspended_until ON users (suspended_until) WHERE suspended_until IS NOT NULL;
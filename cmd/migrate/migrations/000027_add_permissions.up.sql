@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    name varchar(255) NOT NULL UNIQUE,
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint NOT NULL,
+    permission_id bigint NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('post.update.any', 'update posts of other users'),
+    ('post.delete.any', 'delete posts of other users'),
+    ('comment.update.any', 'update comments of other users'),
+    ('comment.delete.any', 'delete comments of other users'),
+    ('users.manage', 'list, suspend, unlock and delete accounts and change their roles'),
+    ('roles.manage', 'manage roles and their permissions'),
+    ('two_factor.required', 'use the other permissions only with two-factor authentication enabled')
+ON CONFLICT (name) DO NOTHING;
+
+-- the seeded roles keep the rights their levels gave them
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON
+    (r.name = 'moderator' AND p.name IN ('post.update.any', 'comment.update.any'))
+    OR (r.name = 'admin' AND p.name != 'two_factor.required')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE name = 'users.moderate';
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS banned_by,
+DROP COLUMN IF EXISTS ban_reason,
+DROP COLUMN IF EXISTS banned_at,
+DROP COLUMN IF EXISTS suspended_by;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE users
+ADD COLUMN suspended_by bigint REFERENCES users (id) ON DELETE SET NULL,
+ADD COLUMN banned_at timestamp with time zone,
+ADD COLUMN ban_reason text NOT NULL DEFAULT '',
+ADD COLUMN banned_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+INSERT INTO permissions (name, description)
+VALUES ('users.moderate', 'suspend, ban and reactivate accounts')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('moderator', 'admin') AND p.name = 'users.moderate'
+ON CONFLICT DO NOTHING;
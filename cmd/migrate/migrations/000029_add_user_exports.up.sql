@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_exports (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    archive bytea,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    started_at timestamp with time zone,
+    completed_at timestamp with time zone,
+    expires_at timestamp with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_exports_user_id ON user_exports (user_id, created_at DESC);
+
+-- a user waits for one export at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_exports_unfinished ON user_exports (user_id)
+WHERE status IN ('pending', 'processing');
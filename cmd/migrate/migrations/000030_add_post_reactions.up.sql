@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    reaction varchar(32) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_id_created_at ON post_reactions (post_id, created_at, user_id);
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id ON post_reactions (user_id);
+
+-- counts per reaction, kept up to date with post_reactions so the feed reads
+-- them without aggregating
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reaction_counts jsonb NOT NULL DEFAULT '{}';
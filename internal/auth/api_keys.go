@@ -0,0 +1,19 @@
+package auth
+
+// apiKeyPrefix makes keys recognizable, e.g. for secret scanners.
+const apiKeyPrefix = "gbk_"
+
+// GenerateAPIKey returns a new personal API key and the hash to store.
+func GenerateAPIKey() (string, string, error) {
+	token, _, err := generateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	key := apiKeyPrefix + token
+	return key, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	return hashRefreshToken(key)
+}
@@ -5,4 +5,7 @@ import "github.com/golang-jwt/jwt/v5"
 type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
+	GenerateRefreshToken() (token string, hash string, err error)
+	HashRefreshToken(token string) string
+	JWKS() JSONWebKeySet
 }
@@ -1,29 +1,63 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"slices"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTAuthenticator struct {
-	secret string
-	aud    string
-	iss    string
+	signingKey *SigningKey
+	keys       map[string]*SigningKey
+	keyList    []*SigningKey
+	methods    []string
+	aud        string
+	iss        string
 }
 
+// NewJWTAuthenticator signs and validates tokens with a shared HS256 secret.
 func NewJWTAuthenticator(secret, aud, iss string) *JWTAuthenticator {
-	return &JWTAuthenticator{
-		secret: secret,
-		aud:    aud,
-		iss:    iss,
+	return NewJWTKeySetAuthenticator(NewHMACKey("", secret), nil, aud, iss)
+}
+
+// NewJWTKeySetAuthenticator signs tokens with signingKey and accepts tokens
+// signed by it or by any of the verification keys, which allows rotating
+// keys without invalidating tokens that are still in flight.
+func NewJWTKeySetAuthenticator(signingKey *SigningKey, verificationKeys []*SigningKey, aud, iss string) *JWTAuthenticator {
+	a := &JWTAuthenticator{
+		signingKey: signingKey,
+		keys:       make(map[string]*SigningKey),
+		aud:        aud,
+		iss:        iss,
+	}
+
+	for _, key := range append([]*SigningKey{signingKey}, verificationKeys...) {
+		if _, exists := a.keys[key.ID]; exists {
+			continue
+		}
+		a.keys[key.ID] = key
+		a.keyList = append(a.keyList, key)
+
+		if !slices.Contains(a.methods, key.Method.Alg()) {
+			a.methods = append(a.methods, key.Method.Alg())
+		}
 	}
+
+	return a
 }
 
 func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(a.signingKey.Method, claims)
+	if a.signingKey.ID != "" {
+		token.Header["kid"] = a.signingKey.ID
+	}
 
-	tokenString, err := token.SignedString([]byte(a.secret))
+	tokenString, err := token.SignedString(a.signingKey.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -33,14 +67,60 @@ func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
-		return []byte(a.secret), nil
+
+		return key.verifyKey, nil
 	},
 		jwt.WithExpirationRequired(),
 		jwt.WithAudience(a.aud),
 		jwt.WithIssuer(a.iss),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithValidMethods(a.methods),
 	)
 }
+
+// JWKS returns the public keys tokens can be verified with. Shared secrets
+// are never included.
+func (a *JWTAuthenticator) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range a.keyList {
+		if key.isPublic() {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+
+	return set
+}
+
+// GenerateRefreshToken returns an opaque random token together with the
+// hash that should be persisted instead of the plain value.
+func (a *JWTAuthenticator) GenerateRefreshToken() (string, string, error) {
+	return generateRefreshToken()
+}
+
+func (a *JWTAuthenticator) HashRefreshToken(token string) string {
+	return hashRefreshToken(token)
+}
+
+func generateRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key used to sign or verify tokens. Keys loaded from a
+// public key only can verify tokens, which is how retired keys are kept
+// around during a rotation.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+func NewHMACKey(id, secret string) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// LoadSigningKey reads a PEM encoded RSA (RS256) or Ed25519 (EdDSA) private
+// key. When id is empty the RFC 7638 thumbprint of the public key is used.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	key, err := loadKey(id, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.signKey == nil {
+		return nil, fmt.Errorf("%s: signing requires a private key", path)
+	}
+
+	return key, nil
+}
+
+// LoadVerificationKeys parses a comma separated list of "kid=path" or "path"
+// entries pointing at PEM encoded public or private keys.
+func LoadVerificationKeys(spec string) ([]*SigningKey, error) {
+	var keys []*SigningKey
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, path, found := strings.Cut(entry, "=")
+		if !found {
+			id, path = "", entry
+		}
+
+		key, err := loadKey(id, path)
+		if err != nil {
+			return nil, err
+		}
+
+		// a verification key never signs, even when loaded from a private key
+		key.signKey = nil
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func loadKey(id, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key := &SigningKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, parsed)
+	}
+
+	if key.ID == "" {
+		key.ID, err = thumbprint(key.JWK())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWK returns the public part of the key. Symmetric keys are never
+// published, so the zero value is returned for them.
+func (k *SigningKey) JWK() JSONWebKey {
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return JSONWebKey{}
+	}
+}
+
+func (k *SigningKey) isPublic() bool {
+	_, symmetric := k.verifyKey.([]byte)
+	return !symmetric
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, which only covers the
+// required members of the key in lexicographic order.
+func thumbprint(jwk JSONWebKey) (string, error) {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", errors.New("unsupported key type for thumbprint")
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
@@ -17,6 +17,8 @@ const testBasicPass = "test_password"
 
 var testClaims = jwt.MapClaims{
 	"sub": 1,
+	"jti": "00000000-0000-0000-0000-000000000001",
+	"ver": 0,
 	"aud": "test_audience",
 	"iss": "test_issuer",
 	"exp": time.Now().Add(time.Hour).Unix(),
@@ -38,6 +40,18 @@ func (a *TestAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	})
 }
 
+func (a *TestAuthenticator) GenerateRefreshToken() (string, string, error) {
+	return generateRefreshToken()
+}
+
+func (a *TestAuthenticator) HashRefreshToken(token string) string {
+	return hashRefreshToken(token)
+}
+
+func (a *TestAuthenticator) JWKS() JSONWebKeySet {
+	return JSONWebKeySet{Keys: []JSONWebKey{}}
+}
+
 func (a *TestAuthenticator) ValidateBasicAuth(authHeader string) error {
 	if authHeader == "" {
 		return errors.New("missing auth token")
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OAuthProviderConfig describes an OAuth2 provider. Providers that support
+// OpenID Connect only need an Issuer, their endpoints are discovered.
+type OAuthProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	// EmailsURL lists the user's addresses for providers whose user info
+	// has no verified flag, e.g. GitHub.
+	EmailsURL string
+	Scopes    []string
+	Claims    UserInfoClaims
+}
+
+// UserInfoClaims names the user info members holding the identity. Empty
+// members fall back to the OpenID Connect standard claims.
+type UserInfoClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+}
+
+// ExternalIdentity is the user as seen by an OAuth provider.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(state, codeChallenge, redirectURL string) string
+	Exchange(ctx context.Context, code, codeVerifier, redirectURL string) (*ExternalIdentity, error)
+}
+
+type oauthProvider struct {
+	cfg    OAuthProviderConfig
+	client *http.Client
+}
+
+// NewOAuthProvider returns a provider for the authorization code flow with
+// PKCE. Endpoints missing from the config are discovered from the issuer.
+func NewOAuthProvider(ctx context.Context, cfg OAuthProviderConfig, client *http.Client) (OAuthProvider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	p := &oauthProvider{cfg: cfg, client: client}
+	if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" {
+		if err := p.discover(ctx); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Name, err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *oauthProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *oauthProvider) AuthCodeURL(state, codeChallenge, redirectURL string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.cfg.AuthURL + sep + params.Encode()
+}
+
+func (p *oauthProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURL string) (*ExternalIdentity, error) {
+	accessToken, err := p.exchangeCode(ctx, code, codeVerifier, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var info map[string]any
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+
+	claims := p.cfg.Claims
+	identity := &ExternalIdentity{
+		Provider:      p.cfg.Name,
+		Subject:       claimString(info, claims.Subject, "sub"),
+		Email:         claimString(info, claims.Email, "email"),
+		EmailVerified: claimBool(info, claims.EmailVerified, "email_verified"),
+		Name:          claimString(info, claims.Name, "name"),
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("user info has no subject")
+	}
+
+	if p.cfg.EmailsURL != "" {
+		if err := p.primaryEmail(ctx, accessToken, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *oauthProvider) exchangeCode(ctx context.Context, code, codeVerifier, redirectURL string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return "", err
+	}
+
+	// some providers report errors with a 200 status
+	if token.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s", token.Error)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token exchange returned no access token")
+	}
+
+	return token.AccessToken, nil
+}
+
+func (p *oauthProvider) primaryEmail(ctx context.Context, accessToken string, identity *ExternalIdentity) error {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.cfg.EmailsURL, accessToken, &emails); err != nil {
+		return err
+	}
+
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email, identity.EmailVerified = e.Email, e.Verified
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *oauthProvider) discover(ctx context.Context) error {
+	if p.cfg.Issuer == "" {
+		return errors.New("either an issuer or all endpoints are required")
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &doc); err != nil {
+		return err
+	}
+
+	if doc.Issuer != p.cfg.Issuer {
+		return fmt.Errorf("discovered issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+
+	if p.cfg.AuthURL == "" {
+		p.cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if p.cfg.TokenURL == "" {
+		p.cfg.TokenURL = doc.TokenEndpoint
+	}
+	if p.cfg.UserInfoURL == "" {
+		p.cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+
+	return nil
+}
+
+func (p *oauthProvider) getJSON(ctx context.Context, endpoint, accessToken string, data any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	return p.do(req, data)
+}
+
+func (p *oauthProvider) do(req *http.Request, data any) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Redacted(), res.StatusCode)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keeps numeric ids such as GitHub's from turning into floats
+	decoder.UseNumber()
+	return decoder.Decode(data)
+}
+
+func claimString(info map[string]any, name, fallback string) string {
+	if name == "" {
+		name = fallback
+	}
+
+	switch v := info[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+func claimBool(info map[string]any, name, fallback string) bool {
+	if name == "" {
+		name = fallback
+	}
+
+	switch v := info[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// GeneratePKCE returns a random code verifier and its S256 challenge.
+func GeneratePKCE() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+func PKCEChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,23 @@
+package auth
+
+// Known providers. Only the client credentials have to be filled in.
+var (
+	GoogleProvider = OAuthProviderConfig{
+		Name:   "google",
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	}
+
+	GitHubProvider = OAuthProviderConfig{
+		Name:        "github",
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Scopes:      []string{"read:user", "user:email"},
+		Claims: UserInfoClaims{
+			Subject: "id",
+			Name:    "login",
+		},
+	}
+)
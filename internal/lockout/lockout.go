@@ -0,0 +1,38 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Tracker counts failed attempts per key and locks the key out for an
+// exponentially growing duration once the threshold is reached.
+type Tracker interface {
+	// Locked returns how long the key stays locked, or 0.
+	Locked(ctx context.Context, key string) (time.Duration, error)
+	// Fail records a failed attempt and returns the lockout it started, or 0.
+	Fail(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type Config struct {
+	Threshold    int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+// lockDuration doubles the lockout with every failure past the threshold.
+func (c Config) lockDuration(failures int) time.Duration {
+	if failures < c.Threshold {
+		return 0
+	}
+
+	d := c.BaseDuration
+	for i := c.Threshold; i < failures && d < c.MaxDuration; i++ {
+		d *= 2
+	}
+
+	return min(d, c.MaxDuration)
+}
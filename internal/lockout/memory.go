@@ -0,0 +1,92 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type MemoryTracker struct {
+	sync.Mutex
+	entries   map[string]*entry
+	cfg       Config
+	lastSweep time.Time
+}
+
+var _ Tracker = (*MemoryTracker)(nil)
+
+func NewMemoryTracker(cfg Config) *MemoryTracker {
+	return &MemoryTracker{
+		entries:   make(map[string]*entry),
+		cfg:       cfg,
+		lastSweep: time.Now(),
+	}
+}
+
+func (t *MemoryTracker) Locked(ctx context.Context, key string) (time.Duration, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return max(time.Until(e.lockedUntil), 0), nil
+}
+
+func (t *MemoryTracker) Fail(ctx context.Context, key string) (time.Duration, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	t.sweep(now)
+
+	e, ok := t.entries[key]
+	if !ok || t.expired(e, now) {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	d := t.cfg.lockDuration(e.failures)
+	if d > 0 {
+		e.lockedUntil = now.Add(d)
+	}
+
+	return d, nil
+}
+
+func (t *MemoryTracker) Reset(ctx context.Context, key string) error {
+	t.Lock()
+	delete(t.entries, key)
+	t.Unlock()
+	return nil
+}
+
+func (t *MemoryTracker) expired(e *entry, now time.Time) bool {
+	return now.After(e.lastFailure.Add(t.cfg.Window)) && now.After(e.lockedUntil)
+}
+
+// sweep drops forgotten entries at most once per window, so keys of
+// one-off failures do not pile up.
+func (t *MemoryTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.cfg.Window {
+		return
+	}
+
+	for key, e := range t.entries {
+		if t.expired(e, now) {
+			delete(t.entries, key)
+		}
+	}
+	t.lastSweep = now
+}
@@ -0,0 +1,64 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RedisTracker struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+var _ Tracker = (*RedisTracker)(nil)
+
+func NewRedisTracker(rdb *redis.Client, cfg Config) *RedisTracker {
+	return &RedisTracker{rdb: rdb, cfg: cfg}
+}
+
+func (t *RedisTracker) Locked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := t.rdb.PTTL(ctx, lockedKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// negative values mean the key does not exist or has no expiry
+	return max(ttl, 0), nil
+}
+
+func (t *RedisTracker) Fail(ctx context.Context, key string) (time.Duration, error) {
+	failures, err := t.rdb.Incr(ctx, failuresKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	d := t.cfg.lockDuration(int(failures))
+
+	// failures have to outlive the lockout to keep escalating it
+	if err := t.rdb.PExpire(ctx, failuresKey(key), t.cfg.Window+d).Err(); err != nil {
+		return 0, err
+	}
+
+	if d > 0 {
+		if err := t.rdb.Set(ctx, lockedKey(key), 1, d).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return d, nil
+}
+
+func (t *RedisTracker) Reset(ctx context.Context, key string) error {
+	return t.rdb.Del(ctx, failuresKey(key), lockedKey(key)).Err()
+}
+
+func failuresKey(key string) string {
+	return fmt.Sprintf("lockout-failures-%s", key)
+}
+
+func lockedKey(key string) string {
+	return fmt.Sprintf("lockout-locked-%s", key)
+}
@@ -3,9 +3,13 @@ package mailer
 import "embed"
 
 const (
-	FromName    = "GoBlog"
-	maxRetries  = 3
-	UserWelcome = "user_invitation.templ"
+	FromName      = "GoBlog"
+	maxRetries    = 3
+	UserWelcome   = "user_invitation.templ"
+	PasswordReset = "password_reset.templ"
+	AccountLocked = "account_locked.templ"
+	EmailChange   = "email_change.templ"
+	DataExport    = "data_export.templ"
 )
 
 //go:embed "templates"
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKey struct {
+	ID        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	Name      string     `json:"name"`
+	Key       string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	Expiry    *time.Time `json:"expiry"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+type APIKeyStore struct {
+	db *sql.DB
+}
+
+func (s *APIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, key, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		key.UserId,
+		key.Name,
+		key.Key,
+		pq.Array(key.Scopes),
+		key.Expiry,
+	).Scan(
+		&key.ID,
+		&key.CreatedAt,
+	)
+}
+
+// GetByKey returns the key with the given hash unless it has expired.
+func (s *APIKeyStore) GetByKey(ctx context.Context, hashKey string) (*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expiry, created_at
+		FROM api_keys
+		WHERE key = $1 AND (expiry IS NULL OR expiry > $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	key := &APIKey{}
+	err := s.db.QueryRowContext(ctx, query, hashKey, time.Now()).Scan(
+		&key.ID,
+		&key.UserId,
+		&key.Name,
+		pq.Array(&key.Scopes),
+		&key.Expiry,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyStore) GetByUser(ctx context.Context, userId int64) ([]APIKey, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expiry, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(
+			&key.ID,
+			&key.UserId,
+			&key.Name,
+			pq.Array(&key.Scopes),
+			&key.Expiry,
+			&key.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *APIKeyStore) Delete(ctx context.Context, keyId, userId int64) error {
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, keyId, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
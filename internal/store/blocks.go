@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UserEntry is a user in a block or mute list.
+type UserEntry struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e UserEntry) Cursor() Cursor {
+	return Cursor{CreatedAt: e.CreatedAt, ID: e.ID}
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block makes blockerId block blockedId and ends every follow and pending
+// follow request between them. It returns ErrorNotFound when the blocked
+// user does not exist.
+func (s *BlockStore) Block(ctx context.Context, blockerId, blockedId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO user_blocks (blocker_id, blocked_id)
+			VALUES ($1, $2)
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case "23505":
+					return ErrorAlreadyExists
+				case "23503":
+					return ErrorNotFound
+				}
+			}
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`
+		_, err := tx.ExecContext(ctx, query, blockerId, blockedId)
+		return err
+	})
+}
+
+// Unblock lifts the block. Follows ended by the block are not restored.
+func (s *BlockStore) Unblock(ctx context.Context, blockerId, blockedId int64) error {
+	query := `
+		DELETE FROM user_blocks
+		WHERE blocker_id = $1 AND blocked_id = $2
+	`
+
+	return execOne(ctx, s.db, query, blockerId, blockedId)
+}
+
+// GetBlocked lists the users userId blocked, newest first.
+func (s *BlockStore) GetBlocked(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error) {
+	page, order := keysetPage(q.Cursor, "b.created_at", "b.blocked_id", true, "$2", "$3")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+
+	return listUserEntries(ctx, s.db, query, userId, q)
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userId, otherId int64) (bool, error) {
+	query := `SELECT ` + isBlocked("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userId, otherId).Scan(&blocked)
+	return blocked, err
+}
+
+// execOne runs a statement that must change a row and returns ErrorNotFound
+// when it changed none.
+func execOne(ctx context.Context, db *sql.DB, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func listUserEntries(ctx context.Context, db *sql.DB, query string, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	createdAt, id := q.position()
+	rows, err := db.QueryContext(ctx, query, userId, createdAt, id, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []UserEntry{}
+	for rows.Next() {
+		var e UserEntry
+		if err := rows.Scan(&e.ID, &e.Username, &e.AvatarURL, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return inListOrder(entries, q.Cursor), rows.Err()
+}
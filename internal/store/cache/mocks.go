@@ -3,13 +3,16 @@ package cache
 import (
 	"context"
 	"social/internal/store"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:         &MockUserStore{},
+		RevokedTokens: &MockRevokedTokensStore{},
+		Timelines:     &MockTimelinesStore{},
 	}
 }
 
@@ -17,6 +20,14 @@ type MockUserStore struct {
 	mock.Mock
 }
 
+type MockRevokedTokensStore struct {
+	mock.Mock
+}
+
+type MockTimelinesStore struct {
+	mock.Mock
+}
+
 func (m *MockUserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
 	args := m.Called(userID)
 
@@ -33,3 +44,47 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) {
 	m.Called(userID)
 }
+
+func (m *MockRevokedTokensStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	args := m.Called(jti, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokensStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTimelinesStore) Get(ctx context.Context, userId int64) ([]store.TimelineEntry, error) {
+	args := m.Called(userId)
+	return args.Get(0).([]store.TimelineEntry), args.Error(1)
+}
+
+func (m *MockTimelinesStore) Push(ctx context.Context, entry store.TimelineEntry, userIds []int64, size int) error {
+	args := m.Called(entry, userIds, size)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesStore) Replace(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	args := m.Called(userId, entries)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesStore) Delete(ctx context.Context, userId int64) {
+	m.Called(userId)
+}
+
+func (m *MockTimelinesStore) AddCelebrity(ctx context.Context, userId int64) error {
+	args := m.Called(userId)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesStore) GetCelebrities(ctx context.Context) ([]int64, error) {
+	args := m.Called()
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockTimelinesStore) ReplaceCelebrities(ctx context.Context, userIds []int64) error {
+	args := m.Called(userIds)
+	return args.Error(0)
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RevokedTokensStore struct {
+	rdb *redis.Client
+}
+
+func (s *RevokedTokensStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+	return s.rdb.Set(ctx, cacheKey, 1, ttl).Err()
+}
+
+func (s *RevokedTokensStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	n, err := s.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"social/internal/store"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -11,11 +12,27 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64)
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, ttl time.Duration) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+	}
+	Timelines interface {
+		Get(context.Context, int64) ([]store.TimelineEntry, error)
+		Push(ctx context.Context, entry store.TimelineEntry, userIds []int64, size int) error
+		Replace(ctx context.Context, userId int64, entries []store.TimelineEntry) error
+		Delete(context.Context, int64)
+		AddCelebrity(context.Context, int64) error
+		GetCelebrities(context.Context) ([]int64, error)
+		ReplaceCelebrities(context.Context, []int64) error
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UsersStore{rdb: rdb},
+		Users:         &UsersStore{rdb: rdb},
+		RevokedTokens: &RevokedTokensStore{rdb: rdb},
+		Timelines:     &TimelinesStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TimelinesStore keeps the home timeline of each user as a sorted set of
+// post ids scored by their creation time.
+type TimelinesStore struct {
+	rdb *redis.Client
+}
+
+// TimelineExpTime drops the timelines of inactive users. A missing timeline
+// is read from Postgres.
+const TimelineExpTime = time.Duration(7 * 24 * time.Hour)
+
+const celebritiesKey = "timeline-celebrities"
+
+func timelineKey(userId int64) string {
+	return fmt.Sprintf("timeline-%v", userId)
+}
+
+// Get returns the timeline of the user, newest entry first. It returns no
+// entries when the user has no timeline.
+func (s *TimelinesStore) Get(ctx context.Context, userId int64) ([]store.TimelineEntry, error) {
+	members, err := s.rdb.ZRevRangeWithScores(ctx, timelineKey(userId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]store.TimelineEntry, 0, len(members))
+	for _, member := range members {
+		postId, err := strconv.ParseInt(member.Member.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, store.TimelineEntry{
+			PostId:    postId,
+			CreatedAt: time.UnixMicro(int64(member.Score)),
+		})
+	}
+
+	return entries, nil
+}
+
+// Push adds the entry to the timelines of the users and trims each of them
+// to its size newest entries.
+func (s *TimelinesStore) Push(ctx context.Context, entry store.TimelineEntry, userIds []int64, size int) error {
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userId := range userIds {
+			key := timelineKey(userId)
+			pipe.ZAdd(ctx, key, timelineMember(entry))
+			pipe.ZRemRangeByRank(ctx, key, 0, int64(-size-1))
+			pipe.Expire(ctx, key, TimelineExpTime)
+		}
+		return nil
+	})
+	return err
+}
+
+// Replace swaps the timeline of the user for the entries.
+func (s *TimelinesStore) Replace(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userId)
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(entries) == 0 {
+			return nil
+		}
+
+		members := make([]*redis.Z, len(entries))
+		for i, entry := range entries {
+			members[i] = timelineMember(entry)
+		}
+		pipe.ZAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, TimelineExpTime)
+		return nil
+	})
+	return err
+}
+
+func (s *TimelinesStore) Delete(ctx context.Context, userId int64) {
+	s.rdb.Del(ctx, timelineKey(userId))
+}
+
+// AddCelebrity marks the user as an author whose posts are read with the
+// feed instead of being pushed to timelines.
+func (s *TimelinesStore) AddCelebrity(ctx context.Context, userId int64) error {
+	return s.rdb.SAdd(ctx, celebritiesKey, userId).Err()
+}
+
+func (s *TimelinesStore) GetCelebrities(ctx context.Context) ([]int64, error) {
+	members, err := s.rdb.SMembers(ctx, celebritiesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ReplaceCelebrities swaps the set of celebrities for the users.
+func (s *TimelinesStore) ReplaceCelebrities(ctx context.Context, userIds []int64) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, celebritiesKey)
+		if len(userIds) == 0 {
+			return nil
+		}
+
+		members := make([]any, len(userIds))
+		for i, id := range userIds {
+			members[i] = id
+		}
+		pipe.SAdd(ctx, celebritiesKey, members...)
+		return nil
+	})
+	return err
+}
+
+// timelineMember scores the entry by its creation time in microseconds,
+// which a float64 holds exactly.
+func timelineMember(entry store.TimelineEntry) *redis.Z {
+	return &redis.Z{
+		Score:  float64(entry.CreatedAt.UnixMicro()),
+		Member: strconv.FormatInt(entry.PostId, 10),
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 )
 
@@ -15,6 +16,10 @@ type Comment struct {
 	User      User      `json:"user"`
 }
 
+func (c Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.Id}
+}
+
 type CommentsStore struct {
 	db *sql.DB
 }
@@ -45,15 +50,20 @@ func (s *CommentsStore) GetById(ctx context.Context, id int64) (*Comment, error)
 	return &c, nil
 }
 
-func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Comment, error) {
+// GetByPostId lists the comments of a post that the viewer may see. Nothing
+// is listed when the post itself is hidden from the viewer.
+func (s *CommentsStore) GetByPostId(ctx context.Context, postId, viewerId int64) ([]Comment, error) {
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username
 		FROM comments c
 		JOIN users ON c.user_id = users.id
+		JOIN posts p ON c.post_id = p.id
 		WHERE c.post_id = $1
+		AND ` + canViewAuthor("c.user_id", "$2") + `
+		AND ` + canViewAuthor("p.user_id", "$2") + `
 		ORDER BY c.created_at DESC
  	`
-	rows, err := s.db.QueryContext(ctx, query, postId)
+	rows, err := s.db.QueryContext(ctx, query, postId, viewerId)
 	if err != nil {
 		return nil, err
 	}
@@ -83,15 +93,115 @@ func (s *CommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Commen
 	return comments, nil
 }
 
+// ListByPostId returns a page of the comments on the post the viewer may see,
+// newest first.
+func (s *CommentsStore) ListByPostId(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Comment, error) {
+	page, order := keysetPage(q.Cursor, "c.created_at", "c.id", true, "$3", "$4")
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username
+		FROM comments c
+		JOIN users ON c.user_id = users.id
+		JOIN posts p ON c.post_id = p.id
+		WHERE c.post_id = $1
+		AND ` + canViewAuthor("c.user_id", "$2") + `
+		AND ` + canViewAuthor("p.user_id", "$2") + `
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $5
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	createdAt, id := q.position()
+	rows, err := s.db.QueryContext(ctx, query, postId, viewerId, createdAt, id, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(
+			&c.Id,
+			&c.PostId,
+			&c.UserId,
+			&c.Content,
+			&c.CreatedAt,
+			&c.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return inListOrder(comments, q.Cursor), rows.Err()
+}
+
+// GetByUser returns every comment the user wrote, oldest first.
+func (s *CommentsStore) GetByUser(ctx context.Context, userId int64) ([]Comment, error) {
+	query := `
+		SELECT id, post_id, user_id, content, created_at
+		FROM comments
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(
+			&c.Id,
+			&c.PostId,
+			&c.UserId,
+			&c.Content,
+			&c.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+// Create adds the comment. It returns ErrorNotFound when the post does not
+// exist or the commenter may not see it, and ErrorBlocked when the commenter
+// and the post author blocked each other.
 func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-	INSERT INTO comments (post_id, user_id, content) 
-	VALUES ($1, $2, $3) 
-	RETURNING id, created_at
+	WITH post AS (
+		SELECT ` + isBlocked("p.user_id", "$2::bigint") + ` AS blocked,
+			` + canViewAuthor("p.user_id", "$2::bigint") + ` AS visible
+		FROM posts p
+		WHERE p.id = $1
+	), comment AS (
+		INSERT INTO comments (post_id, user_id, content)
+		SELECT $1, $2, $3
+		FROM post
+		WHERE visible
+		RETURNING id, created_at
+	)
+	SELECT post.blocked, comment.id, comment.created_at
+	FROM post
+	LEFT JOIN comment ON true
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	var blocked bool
+	var id sql.NullInt64
+	var createdAt sql.NullTime
 	err := s.db.QueryRowContext(
 		ctx,
 		query,
@@ -99,13 +209,28 @@ func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 		comment.UserId,
 		comment.Content,
 	).Scan(
-		&comment.Id,
-		&comment.CreatedAt,
+		&blocked,
+		&id,
+		&createdAt,
 	)
-
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrorNotFound
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case blocked:
+		return ErrorBlocked
+	case !id.Valid:
+		return ErrorNotFound
 	}
+
+	comment.Id = id.Int64
+	comment.CreatedAt = createdAt.Time
 	return nil
 }
 
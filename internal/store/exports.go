@@ -0,0 +1,225 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+)
+
+// ExportStaleAfter is how long an export may stay in processing before
+// another worker takes it over, in case the first one stopped midway.
+var ExportStaleAfter = time.Hour
+
+// Export is a copy of a user's data the user asked for. The archive is kept
+// until ExpiresAt and only loaded by GetArchive.
+type Export struct {
+	ID          string     `json:"id"`
+	UserId      int64      `json:"user_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Archive     []byte     `json:"-"`
+}
+
+type ExportStore struct {
+	db *sql.DB
+}
+
+// Create queues the export. It returns ErrorAlreadyExists while another
+// export of the user is unfinished.
+func (s *ExportStore) Create(ctx context.Context, export *Export) error {
+	query := `
+		INSERT INTO user_exports (id, user_id)
+		VALUES ($1, $2)
+		RETURNING status, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, export.ID, export.UserId).Scan(
+		&export.Status,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrorAlreadyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *ExportStore) GetByUser(ctx context.Context, userId int64) ([]Export, error) {
+	query := `
+		SELECT id, user_id, status, created_at, completed_at, expires_at
+		FROM user_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []Export{}
+	for rows.Next() {
+		var export Export
+		err := rows.Scan(
+			&export.ID,
+			&export.UserId,
+			&export.Status,
+			&export.CreatedAt,
+			&export.CompletedAt,
+			&export.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+// Claim marks the oldest pending export as processing and returns it, so
+// several workers never build the same export. It returns ErrorNotFound when
+// nothing is waiting.
+func (s *ExportStore) Claim(ctx context.Context) (*Export, error) {
+	query := `
+		UPDATE user_exports
+		SET status = 'processing', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_exports
+			WHERE status = 'pending'
+			OR (status = 'processing' AND started_at < NOW() - $1 * interval '1 second')
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var export Export
+	err := s.db.QueryRowContext(ctx, query, ExportStaleAfter.Seconds()).Scan(
+		&export.ID,
+		&export.UserId,
+		&export.Status,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// Complete stores the archive and keeps it for exp.
+func (s *ExportStore) Complete(ctx context.Context, export *Export, exp time.Duration) error {
+	query := `
+		UPDATE user_exports
+		SET status = 'ready', archive = $2, completed_at = NOW(), expires_at = $3
+		WHERE id = $1
+		RETURNING status, completed_at, expires_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, export.ID, export.Archive, time.Now().Add(exp)).Scan(
+		&export.Status,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrorNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ExportStore) Fail(ctx context.Context, exportId string) error {
+	query := `
+		UPDATE user_exports
+		SET status = 'failed', completed_at = NOW()
+		WHERE id = $1
+	`
+
+	return execOne(ctx, s.db, query, exportId)
+}
+
+// GetArchive returns the export with its archive. It returns ErrorNotFound
+// unless the export is ready and has not expired.
+func (s *ExportStore) GetArchive(ctx context.Context, exportId string) (*Export, error) {
+	query := `
+		SELECT id, user_id, status, created_at, completed_at, expires_at, archive
+		FROM user_exports
+		WHERE id = $1 AND status = 'ready' AND expires_at > NOW()
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var export Export
+	err := s.db.QueryRowContext(ctx, query, exportId).Scan(
+		&export.ID,
+		&export.UserId,
+		&export.Status,
+		&export.CreatedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+		&export.Archive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// DeleteExpired drops the archives whose links have expired and returns how
+// many were deleted.
+func (s *ExportStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM user_exports
+		WHERE expires_at < NOW()
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
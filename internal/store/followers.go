@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -13,31 +14,353 @@ type Follower struct {
 	CreatedAt  string `json:"created_at"`
 }
 
+// FollowEntry is a user in a followers or following list.
+type FollowEntry struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	AvatarURL  string    `json:"avatar_url"`
+	FollowedAt time.Time `json:"followed_at"`
+	// Mutual is set when the follow goes both ways.
+	Mutual bool `json:"mutual"`
+}
+
+func (e FollowEntry) Cursor() Cursor {
+	return Cursor{CreatedAt: e.FollowedAt, ID: e.ID}
+}
+
+// Relationship is how a user and another user follow each other.
+type Relationship struct {
+	Following  bool `json:"following"`
+	FollowedBy bool `json:"followed_by"`
+	Mutual     bool `json:"mutual"`
+}
+
 type FollowerStore struct {
 	db *sql.DB
 }
 
+// FollowUser makes followerId follow userId. It returns ErrorNotFound when
+// the followed user does not exist and ErrorBlocked when either user blocked
+// the other.
 func (s *FollowerStore) FollowUser(ctx context.Context, followerId, userId int64) error {
 	query := `
 		INSERT INTO followers (user_id, follower_id) 
-		VALUES ($1, $2)
+		SELECT $1, $2
+		WHERE NOT ` + isBlocked("$1::bigint", "$2::bigint") + `
 	`
 
-	_, err := s.db.ExecContext(ctx, query, followerId, userId)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, followerId)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrorAlreadyExists
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrorAlreadyExists
+			case "23503":
+				return ErrorNotFound
+			}
 		}
+		return err
 	}
-	return err
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorBlocked
+	}
+
+	return nil
 }
 
+// UnfollowUser ends the follow or withdraws the pending follow request.
 func (s *FollowerStore) UnfollowUser(ctx context.Context, followerId, userId int64) error {
 	query := `
-	DELETE FROM followers 
-	WHERE user_id = $1 AND follower_id = $2
+	WITH follow AS (
+		DELETE FROM followers
+		WHERE user_id = $1 AND follower_id = $2
+		RETURNING 1
+	), request AS (
+		DELETE FROM follow_requests
+		WHERE user_id = $1 AND requester_id = $2
+		RETURNING 1
+	)
+	SELECT (SELECT COUNT(*) FROM follow) + (SELECT COUNT(*) FROM request)
 `
 
-	_, err := s.db.ExecContext(ctx, query, followerId, userId)
-	return err
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var deleted int64
+	if err := s.db.QueryRowContext(ctx, query, userId, followerId).Scan(&deleted); err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// GetFollowers lists the users following userId, newest first.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	page, order := keysetPage(q.Cursor, "f.created_at", "f.follower_id", true, "$2", "$3")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, f.created_at,
+			EXISTS (SELECT 1 FROM followers b WHERE b.user_id = f.follower_id AND b.follower_id = f.user_id)
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+
+	return s.list(ctx, query, userId, q)
+}
+
+// GetFollowing lists the users userId follows, newest first.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	page, order := keysetPage(q.Cursor, "f.created_at", "f.user_id", true, "$2", "$3")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, f.created_at,
+			EXISTS (SELECT 1 FROM followers b WHERE b.user_id = f.follower_id AND b.follower_id = f.user_id)
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+
+	return s.list(ctx, query, userId, q)
+}
+
+// RequestFollow asks a private account to accept requesterId as a follower.
+// It returns ErrorAlreadyExists when the request is pending or the follow
+// is in place, and ErrorBlocked when either user blocked the other.
+func (s *FollowerStore) RequestFollow(ctx context.Context, requesterId, userId int64) error {
+	query := `
+	WITH blocked AS (
+		SELECT ` + isBlocked("$1::bigint", "$2::bigint") + ` AS blocked
+	), request AS (
+		INSERT INTO follow_requests (user_id, requester_id)
+		SELECT $1, $2
+		WHERE NOT (SELECT blocked FROM blocked)
+		AND NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+		ON CONFLICT DO NOTHING
+		RETURNING 1
+	)
+	SELECT (SELECT blocked FROM blocked), EXISTS (SELECT 1 FROM request)
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked, requested bool
+	err := s.db.QueryRowContext(ctx, query, userId, requesterId).Scan(&blocked, &requested)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrorNotFound
+		}
+		return err
+	}
+
+	switch {
+	case blocked:
+		return ErrorBlocked
+	case !requested:
+		return ErrorAlreadyExists
+	}
+
+	return nil
+}
+
+// GetFollowRequests lists the pending requests to follow userId, newest
+// first. FollowedAt is the time of the request.
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	page, order := keysetPage(q.Cursor, "fr.created_at", "fr.requester_id", true, "$2", "$3")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, fr.created_at,
+			EXISTS (SELECT 1 FROM followers b WHERE b.user_id = fr.requester_id AND b.follower_id = fr.user_id)
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+
+	return s.list(ctx, query, userId, q)
+}
+
+// ApproveFollowRequest turns the pending request into a follow.
+func (s *FollowerStore) ApproveFollowRequest(ctx context.Context, userId, requesterId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if err := deleteFollowRequest(ctx, tx, userId, requesterId); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO followers (user_id, follower_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		_, err := tx.ExecContext(ctx, query, userId, requesterId)
+		return err
+	})
+}
+
+// ApproveFollowRequests turns every pending request to follow userId into a
+// follow and returns the ids of the requesters.
+func (s *FollowerStore) ApproveFollowRequests(ctx context.Context, userId int64) ([]int64, error) {
+	query := `
+		WITH approved AS (
+			DELETE FROM follow_requests
+			WHERE user_id = $1
+			RETURNING requester_id
+		), followed AS (
+			INSERT INTO followers (user_id, follower_id)
+			SELECT $1, requester_id FROM approved
+			ON CONFLICT DO NOTHING
+		)
+		SELECT requester_id FROM approved
+	`
+
+	return s.listIds(ctx, query, userId)
+}
+
+// DeleteFollowRequest rejects the pending request.
+func (s *FollowerStore) DeleteFollowRequest(ctx context.Context, userId, requesterId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		return deleteFollowRequest(ctx, tx, userId, requesterId)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userId, requesterId int64) error {
+	query := `
+		DELETE FROM follow_requests
+		WHERE user_id = $1 AND requester_id = $2
+	`
+
+	res, err := tx.ExecContext(ctx, query, userId, requesterId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func (s *FollowerStore) list(ctx context.Context, query string, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	createdAt, id := q.position()
+	rows, err := s.db.QueryContext(ctx, query, userId, createdAt, id, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FollowEntry{}
+	for rows.Next() {
+		var e FollowEntry
+		if err := rows.Scan(&e.ID, &e.Username, &e.AvatarURL, &e.FollowedAt, &e.Mutual); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return inListOrder(entries, q.Cursor), rows.Err()
+}
+
+// IsFollowing reports whether followerId follows userId.
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerId, userId int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userId, followerId).Scan(&following)
+	return following, err
+}
+
+// GetRelationship returns how userId and otherId follow each other, seen
+// from userId.
+func (s *FollowerStore) GetRelationship(ctx context.Context, userId, otherId int64) (*Relationship, error) {
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var rel Relationship
+	if err := s.db.QueryRowContext(ctx, query, userId, otherId).Scan(&rel.Following, &rel.FollowedBy); err != nil {
+		return nil, err
+	}
+	rel.Mutual = rel.Following && rel.FollowedBy
+
+	return &rel, nil
+}
+
+// GetFollowerIds returns the ids of all the users following userId.
+func (s *FollowerStore) GetFollowerIds(ctx context.Context, userId int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1`
+
+	return s.listIds(ctx, query, userId)
+}
+
+// GetCelebrities returns the ids of the users with more than threshold
+// followers.
+func (s *FollowerStore) GetCelebrities(ctx context.Context, threshold int) ([]int64, error) {
+	query := `
+		SELECT user_id FROM followers
+		GROUP BY user_id
+		HAVING COUNT(*) > $1
+	`
+
+	return s.listIds(ctx, query, threshold)
+}
+
+func (s *FollowerStore) listIds(ctx context.Context, query string, args ...any) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
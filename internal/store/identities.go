@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OAuth provider.
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserId    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserIdentityStore struct {
+	db *sql.DB
+}
+
+func (s *UserIdentityStore) GetByProvider(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	identity := &UserIdentity{}
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserId,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return identity, nil
+}
+
+// Create links the identity to an existing user.
+func (s *UserIdentityStore) Create(ctx context.Context, identity *UserIdentity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return createUserIdentity(ctx, tx, identity)
+	})
+}
+
+func createUserIdentity(ctx context.Context, tx *sql.Tx, identity *UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserId,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(
+		&identity.ID,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "user_identities_provider_subject_key"`:
+			return ErrorAlreadyExists
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -10,10 +10,21 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users:    &MockUserStore{},
-		Comments: &MockCommentsStore{},
-		Posts:    &MockPostStore{},
-		Roles:    &MockRolesStore{},
+		Users:          &MockUserStore{},
+		Comments:       &MockCommentsStore{},
+		Posts:          &MockPostStore{},
+		Reactions:      &MockReactionStore{},
+		Roles:          &MockRolesStore{},
+		RefreshTokens:  &MockRefreshTokenStore{},
+		RevokedTokens:  &MockRevokedTokenStore{},
+		TwoFactor:      &MockTwoFactorStore{},
+		APIKeys:        &MockAPIKeyStore{},
+		UserIdentities: &MockUserIdentityStore{},
+		Sessions:       &MockSessionStore{},
+		Followers:      &MockFollowerStore{},
+		Blocks:         &MockBlockStore{},
+		Mutes:          &MockMuteStore{},
+		Exports:        &MockExportStore{},
 	}
 }
 
@@ -29,10 +40,54 @@ type MockPostStore struct {
 	mock.Mock
 }
 
+type MockReactionStore struct {
+	mock.Mock
+}
+
 type MockRolesStore struct {
 	mock.Mock
 }
 
+type MockRefreshTokenStore struct {
+	mock.Mock
+}
+
+type MockRevokedTokenStore struct {
+	mock.Mock
+}
+
+type MockTwoFactorStore struct {
+	mock.Mock
+}
+
+type MockAPIKeyStore struct {
+	mock.Mock
+}
+
+type MockUserIdentityStore struct {
+	mock.Mock
+}
+
+type MockSessionStore struct {
+	mock.Mock
+}
+
+type MockFollowerStore struct {
+	mock.Mock
+}
+
+type MockBlockStore struct {
+	mock.Mock
+}
+
+type MockMuteStore struct {
+	mock.Mock
+}
+
+type MockExportStore struct {
+	mock.Mock
+}
+
 func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, u *User) error {
 	return nil
 }
@@ -59,16 +114,114 @@ func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
 	}, nil
 }
 
+func (m *MockUserStore) GetCounts(ctx context.Context, userId int64) (*UserCounts, error) {
+	args := m.Called(ctx, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserCounts), args.Error(1)
+}
+
+func (m *MockUserStore) Search(ctx context.Context, viewerId int64, q UserSearchQuery) ([]UserSearchResult, error) {
+	args := m.Called(ctx, viewerId, q)
+	return args.Get(0).([]UserSearchResult), args.Error(1)
+}
+
+func (m *MockUserStore) List(ctx context.Context, aq AdminUserQuery) ([]User, error) {
+	args := m.Called(ctx, aq)
+	return args.Get(0).([]User), args.Error(1)
+}
+
+func (m *MockUserStore) SetRole(ctx context.Context, userId int64, roleName string) error {
+	args := m.Called(ctx, userId, roleName)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) Suspend(ctx context.Context, userId int64, until time.Time, reason string, moderatorId int64) error {
+	args := m.Called(ctx, userId, until, reason, moderatorId)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) Ban(ctx context.Context, userId int64, reason string, moderatorId int64) error {
+	args := m.Called(ctx, userId, reason, moderatorId)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) Reactivate(ctx context.Context, userId int64) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error {
 	return nil
 }
 
+func (m *MockUserStore) CreateFromIdentity(ctx context.Context, user *User, identity *UserIdentity, token string, exp time.Duration) error {
+	args := m.Called(ctx, user, identity, token, exp)
+	return args.Error(0)
+}
+
 func (m *MockUserStore) Activate(ctx context.Context, t string) error {
 	return nil
 }
 
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
-	return nil
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) Update(ctx context.Context, user *User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) ChangePassword(ctx context.Context, userId int64, password *Password, sessionId string) error {
+	args := m.Called(ctx, userId, password, sessionId)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreateEmailChange(ctx context.Context, userId int64, email, token string, exp time.Duration) error {
+	args := m.Called(ctx, userId, email, token, exp)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserStore) IncrementTokenVersion(ctx context.Context, userId int64) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userId int64, token string, exp time.Duration) error {
+	args := m.Called(ctx, userId, token, exp)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token string, password *Password) (*User, error) {
+	args := m.Called(ctx, token, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserStore) ReplaceInvitation(ctx context.Context, email, token string, exp time.Duration) (*User, error) {
+	args := m.Called(ctx, email, token, exp)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *MockUserStore) VerifyPassword(plainPassword, hashedPassword string) (bool, error) {
@@ -76,13 +229,24 @@ func (m *MockUserStore) VerifyPassword(plainPassword, hashedPassword string) (bo
 	return args.Bool(0), args.Error(1)
 }
 
-func (c *MockCommentsStore) GetByPostId(ctx context.Context, postId int64) ([]Comment, error) {
-	args := c.Called(ctx, postId)
+func (c *MockCommentsStore) GetByPostId(ctx context.Context, postId, viewerId int64) ([]Comment, error) {
+	args := c.Called(ctx, postId, viewerId)
+	return args.Get(0).([]Comment), args.Error(1)
+}
+
+func (c *MockCommentsStore) ListByPostId(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Comment, error) {
+	args := c.Called(ctx, postId, viewerId, q)
+	return args.Get(0).([]Comment), args.Error(1)
+}
+
+func (c *MockCommentsStore) GetByUser(ctx context.Context, userId int64) ([]Comment, error) {
+	args := c.Called(ctx, userId)
 	return args.Get(0).([]Comment), args.Error(1)
 }
 
 func (c *MockCommentsStore) Create(ctx context.Context, comment *Comment) error {
-	return nil
+	args := c.Called(ctx, comment)
+	return args.Error(0)
 }
 
 func (c *MockCommentsStore) Update(ctx context.Context, comment *Comment) error {
@@ -109,6 +273,11 @@ func (p *MockPostStore) GetById(ctx context.Context, postId int64) (Post, error)
 	return args.Get(0).(Post), args.Error(1)
 }
 
+func (p *MockPostStore) GetByUser(ctx context.Context, userId int64) ([]Post, error) {
+	args := p.Called(ctx, userId)
+	return args.Get(0).([]Post), args.Error(1)
+}
+
 func (p *MockPostStore) Delete(ctx context.Context, postId int64) error {
 	args := p.Called(ctx, postId)
 	return args.Error(0)
@@ -127,7 +296,371 @@ func (p *MockPostStore) GetUserFeed(ctx context.Context, userId int64, fq Pagina
 	return args.Get(0).([]PostWithMetadata), args.Error(1)
 }
 
+func (p *MockPostStore) GetExploreFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	args := p.Called(ctx, userId, fq)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]PostWithMetadata), args.Error(1)
+}
+
+func (p *MockPostStore) GetTimelineFeed(ctx context.Context, userId int64, timeline []TimelineEntry, celebrities []int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	args := p.Called(ctx, userId, timeline, celebrities, fq)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]PostWithMetadata), args.Error(1)
+}
+
+func (p *MockPostStore) GetTimeline(ctx context.Context, userId int64, threshold, limit int) ([]TimelineEntry, error) {
+	args := p.Called(ctx, userId, threshold, limit)
+	return args.Get(0).([]TimelineEntry), args.Error(1)
+}
+
+func (p *MockPostStore) GetAuthorAffinities(ctx context.Context, viewerId int64, authorIds []int64) (map[int64]AuthorAffinity, error) {
+	args := p.Called(ctx, viewerId, authorIds)
+	return args.Get(0).(map[int64]AuthorAffinity), args.Error(1)
+}
+
+func (m *MockReactionStore) React(ctx context.Context, postId, userId int64, reaction string) error {
+	args := m.Called(ctx, postId, userId, reaction)
+	return args.Error(0)
+}
+
+func (m *MockReactionStore) Unreact(ctx context.Context, postId, userId int64) error {
+	args := m.Called(ctx, postId, userId)
+	return args.Error(0)
+}
+
+func (m *MockReactionStore) GetReactors(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Reactor, error) {
+	args := m.Called(ctx, postId, viewerId, q)
+	return args.Get(0).([]Reactor), args.Error(1)
+}
+
 func (r *MockRolesStore) GetByName(ctx context.Context, name string) (*Role, error) {
 	args := r.Called(ctx, name)
 	return args.Get(0).(*Role), args.Error(1)
 }
+
+func (r *MockRolesStore) List(ctx context.Context) ([]Role, error) {
+	args := r.Called(ctx)
+	return args.Get(0).([]Role), args.Error(1)
+}
+
+func (r *MockRolesStore) Create(ctx context.Context, role *Role) error {
+	args := r.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (r *MockRolesStore) Delete(ctx context.Context, name string) error {
+	args := r.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (r *MockRolesStore) HasPermission(ctx context.Context, roleName, permission string) (bool, error) {
+	args := r.Called(ctx, roleName, permission)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockRolesStore) ListPermissions(ctx context.Context) ([]Permission, error) {
+	args := r.Called(ctx)
+	return args.Get(0).([]Permission), args.Error(1)
+}
+
+func (r *MockRolesStore) Grant(ctx context.Context, roleName, permission string) error {
+	args := r.Called(ctx, roleName, permission)
+	return args.Error(0)
+}
+
+func (r *MockRolesStore) Revoke(ctx context.Context, roleName, permission string) error {
+	args := r.Called(ctx, roleName, permission)
+	return args.Error(0)
+}
+
+func (r *MockRefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	args := r.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (r *MockRefreshTokenStore) GetByToken(ctx context.Context, hashToken string) (*RefreshToken, error) {
+	args := r.Called(ctx, hashToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (r *MockRefreshTokenStore) Rotate(ctx context.Context, current, next *RefreshToken) error {
+	args := r.Called(ctx, current, next)
+	return args.Error(0)
+}
+
+func (r *MockRefreshTokenStore) RevokeFamily(ctx context.Context, familyId string) error {
+	args := r.Called(ctx, familyId)
+	return args.Error(0)
+}
+
+func (r *MockRefreshTokenStore) RevokeAllByUser(ctx context.Context, userId int64) error {
+	args := r.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (r *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	args := r.Called(ctx, jti, expiry)
+	return args.Error(0)
+}
+
+func (r *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	args := r.Called(ctx, jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (t *MockTwoFactorStore) SetSecret(ctx context.Context, userId int64, secret string) error {
+	args := t.Called(ctx, userId, secret)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) GetSecret(ctx context.Context, userId int64) (string, error) {
+	args := t.Called(ctx, userId)
+	return args.String(0), args.Error(1)
+}
+
+func (t *MockTwoFactorStore) Enable(ctx context.Context, userId int64, recoveryCodes []string) error {
+	args := t.Called(ctx, userId, recoveryCodes)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) Disable(ctx context.Context, userId int64) error {
+	args := t.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) UseTOTPStep(ctx context.Context, userId int64, step int64) error {
+	args := t.Called(ctx, userId, step)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userId int64, code string) error {
+	args := t.Called(ctx, userId, code)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) CreateChallenge(ctx context.Context, userId int64, token string, exp time.Duration) error {
+	args := t.Called(ctx, userId, token, exp)
+	return args.Error(0)
+}
+
+func (t *MockTwoFactorStore) ConsumeChallenge(ctx context.Context, token string) (int64, error) {
+	args := t.Called(ctx, token)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (k *MockAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	args := k.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (k *MockAPIKeyStore) GetByKey(ctx context.Context, hashKey string) (*APIKey, error) {
+	args := k.Called(ctx, hashKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*APIKey), args.Error(1)
+}
+
+func (k *MockAPIKeyStore) GetByUser(ctx context.Context, userId int64) ([]APIKey, error) {
+	args := k.Called(ctx, userId)
+	return args.Get(0).([]APIKey), args.Error(1)
+}
+
+func (k *MockAPIKeyStore) Delete(ctx context.Context, keyId, userId int64) error {
+	args := k.Called(ctx, keyId, userId)
+	return args.Error(0)
+}
+
+func (i *MockUserIdentityStore) GetByProvider(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	args := i.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserIdentity), args.Error(1)
+}
+
+func (i *MockUserIdentityStore) Create(ctx context.Context, identity *UserIdentity) error {
+	args := i.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (s *MockSessionStore) Create(ctx context.Context, session *Session) error {
+	args := s.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (s *MockSessionStore) GetByUser(ctx context.Context, userId int64) ([]Session, error) {
+	args := s.Called(ctx, userId)
+	return args.Get(0).([]Session), args.Error(1)
+}
+
+func (s *MockSessionStore) Touch(ctx context.Context, id string, userId int64) error {
+	args := s.Called(ctx, id, userId)
+	return args.Error(0)
+}
+
+func (s *MockSessionStore) Delete(ctx context.Context, id string, userId int64) error {
+	args := s.Called(ctx, id, userId)
+	return args.Error(0)
+}
+
+func (s *MockSessionStore) DeleteAllByUser(ctx context.Context, userId int64) error {
+	args := s.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (s *MockSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	args := s.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (f *MockFollowerStore) FollowUser(ctx context.Context, followerId, userId int64) error {
+	args := f.Called(ctx, followerId, userId)
+	return args.Error(0)
+}
+
+func (f *MockFollowerStore) UnfollowUser(ctx context.Context, followerId, userId int64) error {
+	args := f.Called(ctx, followerId, userId)
+	return args.Error(0)
+}
+
+func (f *MockFollowerStore) GetFollowers(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	args := f.Called(ctx, userId, q)
+	return args.Get(0).([]FollowEntry), args.Error(1)
+}
+
+func (f *MockFollowerStore) GetFollowing(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	args := f.Called(ctx, userId, q)
+	return args.Get(0).([]FollowEntry), args.Error(1)
+}
+
+func (f *MockFollowerStore) IsFollowing(ctx context.Context, followerId, userId int64) (bool, error) {
+	args := f.Called(ctx, followerId, userId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *MockFollowerStore) GetRelationship(ctx context.Context, userId, otherId int64) (*Relationship, error) {
+	args := f.Called(ctx, userId, otherId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Relationship), args.Error(1)
+}
+
+func (f *MockFollowerStore) RequestFollow(ctx context.Context, requesterId, userId int64) error {
+	args := f.Called(ctx, requesterId, userId)
+	return args.Error(0)
+}
+
+func (f *MockFollowerStore) GetFollowRequests(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error) {
+	args := f.Called(ctx, userId, q)
+	return args.Get(0).([]FollowEntry), args.Error(1)
+}
+
+func (f *MockFollowerStore) ApproveFollowRequest(ctx context.Context, userId, requesterId int64) error {
+	args := f.Called(ctx, userId, requesterId)
+	return args.Error(0)
+}
+
+func (f *MockFollowerStore) ApproveFollowRequests(ctx context.Context, userId int64) ([]int64, error) {
+	args := f.Called(ctx, userId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (f *MockFollowerStore) DeleteFollowRequest(ctx context.Context, userId, requesterId int64) error {
+	args := f.Called(ctx, userId, requesterId)
+	return args.Error(0)
+}
+
+func (f *MockFollowerStore) GetFollowerIds(ctx context.Context, userId int64) ([]int64, error) {
+	args := f.Called(ctx, userId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (f *MockFollowerStore) GetCelebrities(ctx context.Context, threshold int) ([]int64, error) {
+	args := f.Called(ctx, threshold)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (b *MockBlockStore) Block(ctx context.Context, blockerId, blockedId int64) error {
+	args := b.Called(ctx, blockerId, blockedId)
+	return args.Error(0)
+}
+
+func (b *MockBlockStore) Unblock(ctx context.Context, blockerId, blockedId int64) error {
+	args := b.Called(ctx, blockerId, blockedId)
+	return args.Error(0)
+}
+
+func (b *MockBlockStore) GetBlocked(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error) {
+	args := b.Called(ctx, userId, q)
+	return args.Get(0).([]UserEntry), args.Error(1)
+}
+
+func (b *MockBlockStore) IsBlocked(ctx context.Context, userId, otherId int64) (bool, error) {
+	args := b.Called(ctx, userId, otherId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMuteStore) Mute(ctx context.Context, muterId, mutedId int64) error {
+	args := m.Called(ctx, muterId, mutedId)
+	return args.Error(0)
+}
+
+func (m *MockMuteStore) Unmute(ctx context.Context, muterId, mutedId int64) error {
+	args := m.Called(ctx, muterId, mutedId)
+	return args.Error(0)
+}
+
+func (m *MockMuteStore) GetMuted(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error) {
+	args := m.Called(ctx, userId, q)
+	return args.Get(0).([]UserEntry), args.Error(1)
+}
+
+func (m *MockExportStore) Create(ctx context.Context, export *Export) error {
+	args := m.Called(ctx, export)
+	return args.Error(0)
+}
+
+func (m *MockExportStore) GetByUser(ctx context.Context, userId int64) ([]Export, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]Export), args.Error(1)
+}
+
+func (m *MockExportStore) Claim(ctx context.Context) (*Export, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Export), args.Error(1)
+}
+
+func (m *MockExportStore) Complete(ctx context.Context, export *Export, exp time.Duration) error {
+	args := m.Called(ctx, export, exp)
+	return args.Error(0)
+}
+
+func (m *MockExportStore) Fail(ctx context.Context, exportId string) error {
+	args := m.Called(ctx, exportId)
+	return args.Error(0)
+}
+
+func (m *MockExportStore) GetArchive(ctx context.Context, exportId string) (*Export, error) {
+	args := m.Called(ctx, exportId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Export), args.Error(1)
+}
+
+func (m *MockExportStore) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+// Mute hides the posts of mutedId from the feed of muterId. It returns
+// ErrorNotFound when the muted user does not exist.
+func (s *MuteStore) Mute(ctx context.Context, muterId, mutedId int64) error {
+	query := `
+		INSERT INTO user_mutes (muter_id, muted_id)
+		VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterId, mutedId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrorAlreadyExists
+			case "23503":
+				return ErrorNotFound
+			}
+		}
+	}
+	return err
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, muterId, mutedId int64) error {
+	query := `
+		DELETE FROM user_mutes
+		WHERE muter_id = $1 AND muted_id = $2
+	`
+
+	return execOne(ctx, s.db, query, muterId, mutedId)
+}
+
+// GetMuted lists the users userId muted, newest first.
+func (s *MuteStore) GetMuted(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error) {
+	page, order := keysetPage(q.Cursor, "m.created_at", "m.muted_id", true, "$2", "$3")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, m.created_at
+		FROM user_mutes m
+		JOIN users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+
+	return listUserEntries(ctx, s.db, query, userId, q)
+}
@@ -1,16 +1,27 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// SortTop ranks a feed by score instead of creation time.
+const SortTop = "top"
+
+// PaginatedFeedQuery pages through a feed by cursor, or by offset for older
+// clients. A query cannot have both, and a feed sorted by score is only
+// paged by offset.
 type PaginatedFeedQuery struct {
 	Limit  int        `json:"limit" validate:"min=1,max=20"`
 	Offset int        `json:"offset" validate:"min=0"`
-	Sort   string     `json:"sort" validate:"oneof=asc desc"`
+	Cursor *Cursor    `json:"-"`
+	Sort   string     `json:"sort" validate:"oneof=asc desc top"`
 	Tags   []string   `json:"tags" validate:"max=5"`
 	Search string     `json:"search" validate:"max=100"`
 	Since  *time.Time `json:"since"`
@@ -69,9 +80,103 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 			fq.Until = t
 		}
 	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return fq, err
+		}
+		if fq.Offset > 0 {
+			return fq, errors.New("cursor and offset cannot be combined")
+		}
+		if fq.Sort == SortTop {
+			return fq, errors.New("cursor cannot be combined with the top sort")
+		}
+		fq.Cursor = c
+	}
+
 	return fq, nil
 }
 
+// UserSearchQuery searches the user directory. The full mode ranks fuzzy
+// matches on the username, the autocomplete mode only matches username
+// prefixes.
+type UserSearchQuery struct {
+	Search string `json:"search" validate:"required,max=100"`
+	Mode   string `json:"mode" validate:"oneof=full autocomplete"`
+	Limit  int    `json:"limit" validate:"min=1,max=50"`
+	Offset int    `json:"offset" validate:"min=0"`
+}
+
+func (sq UserSearchQuery) Parse(r *http.Request) (UserSearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Search = strings.TrimSpace(qs.Get("search"))
+
+	mode := qs.Get("mode")
+	if mode != "" {
+		sq.Mode = mode
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, nil
+		}
+		sq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return sq, nil
+		}
+		sq.Offset = o
+	}
+
+	return sq, nil
+}
+
+// AdminUserQuery filters the account list of the admin API.
+type AdminUserQuery struct {
+	Search string `json:"search" validate:"max=100"`
+	Role   string `json:"role" validate:"max=255"`
+	Status string `json:"status" validate:"omitempty,oneof=active inactive suspended banned"`
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Offset int    `json:"offset" validate:"min=0"`
+}
+
+func (aq AdminUserQuery) Parse(r *http.Request) (AdminUserQuery, error) {
+	qs := r.URL.Query()
+
+	aq.Search = strings.TrimSpace(qs.Get("search"))
+	aq.Role = qs.Get("role")
+	aq.Status = qs.Get("status")
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return aq, nil
+		}
+		aq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return aq, nil
+		}
+		aq.Offset = o
+	}
+
+	return aq, nil
+}
+
 func parseTime(s string) *time.Time {
 	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
@@ -79,3 +184,125 @@ func parseTime(s string) *time.Time {
 	}
 	return &t
 }
+
+var ErrorInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered by creation time and id. Clients
+// get it as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	// Before asks for the page in front of the position instead of the one
+	// after it.
+	Before bool
+}
+
+const cursorBefore = "before"
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(c.ID, 10)
+	if c.Before {
+		raw += "," + cursorBefore
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrorInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, ErrorInvalidCursor
+	}
+
+	var c Cursor
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return nil, ErrorInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return nil, ErrorInvalidCursor
+	}
+	if len(parts) == 3 {
+		if parts[2] != cursorBefore {
+			return nil, ErrorInvalidCursor
+		}
+		c.Before = true
+	}
+
+	return &c, nil
+}
+
+// keysetPage returns the condition and the ordering that read the page next
+// to the cursor from a list ordered by createdCol and idCol. The cursor's
+// time and id are bound to the at and id placeholders, and a nil time reads
+// the first page. A page before the cursor is read backwards, so its rows
+// have to go through inListOrder.
+func keysetPage(c *Cursor, createdCol, idCol string, descending bool, at, id string) (string, string) {
+	if c != nil && c.Before {
+		descending = !descending
+	}
+
+	op, dir := ">", "ASC"
+	if descending {
+		op, dir = "<", "DESC"
+	}
+
+	condition := fmt.Sprintf("(%[1]s::timestamptz IS NULL OR (%[3]s, %[4]s) %[5]s (%[1]s, %[2]s))", at, id, createdCol, idCol, op)
+	order := fmt.Sprintf("%[1]s %[3]s, %[2]s %[3]s", createdCol, idCol, dir)
+	return condition, order
+}
+
+// inListOrder puts the rows of a page read backwards back in list order.
+func inListOrder[T any](rows []T, c *Cursor) []T {
+	if c != nil && c.Before {
+		slices.Reverse(rows)
+	}
+	return rows
+}
+
+// PaginatedKeysetQuery pages through a list by a cursor next to the page
+// instead of an offset.
+type PaginatedKeysetQuery struct {
+	Limit  int `json:"limit" validate:"min=1,max=100"`
+	Cursor *Cursor
+}
+
+func (q PaginatedKeysetQuery) Parse(r *http.Request) (PaginatedKeysetQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, nil
+		}
+		q.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return q, err
+		}
+		q.Cursor = c
+	}
+
+	return q, nil
+}
+
+// position returns where the cursor points. A nil time starts at the top of
+// the list.
+func (q PaginatedKeysetQuery) position() (*time.Time, int64) {
+	return cursorPosition(q.Cursor)
+}
+
+func cursorPosition(c *Cursor) (*time.Time, int64) {
+	if c == nil {
+		return nil, 0
+	}
+	return &c.CreatedAt, c.ID
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -23,7 +24,36 @@ type Post struct {
 
 type PostWithMetadata struct {
 	Post
-	CommentsCount int `json:"comments_count"`
+	CommentsCount int            `json:"comments_count"`
+	Reactions     ReactionCounts `json:"reactions"`
+	// MyReaction is the viewer's reaction to the post, if any.
+	MyReaction string `json:"my_reaction,omitempty"`
+}
+
+func (p PostWithMetadata) Cursor() Cursor {
+	// CreatedAt holds the timestamp as database/sql formats it
+	createdAt, _ := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	return Cursor{CreatedAt: createdAt, ID: p.ID}
+}
+
+// TimelineEntry is a post in a home timeline materialized outside of
+// Postgres.
+type TimelineEntry struct {
+	PostId    int64
+	CreatedAt time.Time
+}
+
+func (p Post) TimelineEntry() TimelineEntry {
+	createdAt, _ := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	return TimelineEntry{PostId: p.ID, CreatedAt: createdAt}
+}
+
+// AuthorAffinity is how close a viewer is to an author: whether the viewer
+// follows the author and how many of the author's posts the viewer
+// commented on or reacted to.
+type AuthorAffinity struct {
+	Follows      bool
+	Interactions int
 }
 
 type PostStore struct {
@@ -86,6 +116,45 @@ func (s *PostStore) GetById(ctx context.Context, postId int64) (Post, error) {
 	return post, nil
 }
 
+// GetByUser returns every post of the user, oldest first.
+func (s *PostStore) GetByUser(ctx context.Context, userId int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, created_at, updated_at, tags, version
+		FROM posts
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID,
+			&post.UserId,
+			&post.Title,
+			&post.Content,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			pq.Array(&post.Tags),
+			&post.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
 func (s *PostStore) Delete(ctx context.Context, postId int64) error {
 	query := `DELETE FROM posts WHERE id = $1`
 
@@ -130,29 +199,187 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	return nil
 }
 
+// homeFeedScope limits a feed to the posts of the authors the user in $1
+// follows and the user's own posts.
+const homeFeedScope = `(p.user_id = $1 OR EXISTS (
+	SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+))`
+
+// GetUserFeed returns the home feed of the user: the posts of the authors
+// the user follows and the user's own posts.
 func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return s.getFeed(ctx, homeFeedScope, userId, fq)
+}
+
+// GetTimelineFeed returns the home feed of the user from the user's
+// materialized timeline, newest entry first. The timeline holds every post
+// of the authors the user follows since its oldest entry, except for the
+// celebrities, whose posts are never pushed and are merged in by author.
+// Only the pages past the end of the timeline read the whole feed. Entries
+// of authors the user no longer follows are left out.
+func (s *PostStore) GetTimelineFeed(ctx context.Context, userId int64, timeline []TimelineEntry, celebrities []int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	// the oldest posts come first in ascending feeds, which is where the
+	// timeline ends
+	if len(timeline) == 0 || fq.Sort != "desc" {
+		return s.GetUserFeed(ctx, userId, fq)
+	}
+
+	postIds := make([]int64, len(timeline))
+	for i, entry := range timeline {
+		postIds[i] = entry.PostId
+	}
+	oldest := timeline[len(timeline)-1].CreatedAt
+
+	if fq.Cursor != nil && fq.Cursor.CreatedAt.Before(oldest) {
+		return s.GetUserFeed(ctx, userId, fq)
+	}
+
+	scope := `(p.id = ANY($10) OR (p.user_id = ANY($11) AND p.created_at >= $12))
+		AND ` + homeFeedScope
+
+	feed, err := s.getFeed(ctx, scope, userId, fq, pq.Array(postIds), pq.Array(celebrities), oldest)
+	if err != nil || len(feed) >= fq.Limit {
+		return feed, err
+	}
+
+	// a page in front of the cursor is newer than it, and a feed since the
+	// end of the timeline has nothing older to add
+	if (fq.Cursor != nil && fq.Cursor.Before) || (fq.Since != nil && !fq.Since.Before(oldest)) {
+		return feed, nil
+	}
+
+	// the offset may have skipped posts of the timeline, so a page that has
+	// none of them is read from the whole feed
+	if len(feed) == 0 {
+		return s.GetUserFeed(ctx, userId, fq)
+	}
+
+	// the rest of the page is older than the timeline, and so older than
+	// both the cursor and the posts the offset skipped
+	rest := fq
+	rest.Limit = fq.Limit - len(feed)
+	rest.Offset = 0
+	rest.Cursor = nil
+
+	older, err := s.getFeed(ctx, "p.created_at < $10 AND "+homeFeedScope, userId, rest, oldest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(feed, older...), nil
+}
+
+// GetTimeline returns the newest posts of the user's home feed as timeline
+// entries, leaving out the authors with more than threshold followers.
+func (s *PostStore) GetTimeline(ctx context.Context, userId int64, threshold, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $1 OR EXISTS (
+			SELECT 1 FROM followers f
+			WHERE f.user_id = p.user_id AND f.follower_id = $1
+			AND (SELECT COUNT(*) FROM followers c WHERE c.user_id = f.user_id) <= $2
+		)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var entry TimelineEntry
+		if err := rows.Scan(&entry.PostId, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetAuthorAffinities returns the affinity of the viewer to each of the
+// authors, keyed by author id.
+func (s *PostStore) GetAuthorAffinities(ctx context.Context, viewerId int64, authorIds []int64) (map[int64]AuthorAffinity, error) {
+	query := `
+		SELECT a.id,
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = a.id AND f.follower_id = $1),
+			(SELECT COUNT(DISTINCT i.post_id) FROM (
+				SELECT post_id FROM comments WHERE user_id = $1
+				UNION ALL
+				SELECT post_id FROM post_reactions WHERE user_id = $1
+			) i
+			JOIN posts p ON p.id = i.post_id
+			WHERE p.user_id = a.id)
+		FROM unnest($2::bigint[]) AS a(id)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(authorIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	affinities := make(map[int64]AuthorAffinity, len(authorIds))
+	for rows.Next() {
+		var authorId int64
+		var affinity AuthorAffinity
+		if err := rows.Scan(&authorId, &affinity.Follows, &affinity.Interactions); err != nil {
+			return nil, err
+		}
+		affinities[authorId] = affinity
+	}
+
+	return affinities, rows.Err()
+}
+
+// GetExploreFeed returns the posts of everyone but the user that the user
+// is allowed to see.
+func (s *PostStore) GetExploreFeed(ctx context.Context, userId int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return s.getFeed(ctx, "p.user_id != $1", userId, fq)
+}
+
+// getFeed lists the posts matching scope, a condition on the post p and the
+// viewer in $1, narrowed down by the filters of the feed query. The scope's
+// own arguments are bound from $10 on.
+func (s *PostStore) getFeed(ctx context.Context, scope string, userId int64, fq PaginatedFeedQuery, scopeArgs ...any) ([]PostWithMetadata, error) {
+	page, order := keysetPage(fq.Cursor, "p.created_at", "p.id", fq.Sort == "desc", "$8", "$9")
 	query := `
 		SELECT 
    		p.id, p.title, p.user_id, p.content, p.created_at, p.tags, p.updated_at, p.version,
   		COUNT(c.id) AS comments_count,
- 		u.username
+ 		u.username, p.reaction_counts,
+		(SELECT r.reaction FROM post_reactions r WHERE r.post_id = p.id AND r.user_id = $1)
 		FROM public.posts p
 		LEFT JOIN comments c ON c.post_id = p.id
 		LEFT JOIN users u ON p.user_id = u.id
 		WHERE
-		p.user_id != $1 
+		` + scope + `
+		AND ` + canViewAuthor("p.user_id", "$1") + `
+		AND NOT ` + isMuted("p.user_id", "$1") + `
     	AND ((p.title ILIKE '%' || $4 || '%') OR (p.content ILIKE '%' || $4 || '%'))
     	AND (p.tags @> $5 OR $5 = '{}')
     	AND ((p.created_at >= $6 OR $6 IS NULL) AND (p.created_at <= $7 OR $7 IS NULL))
+		AND ` + page + `
 		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + fq.Sort + `
+		ORDER BY ` + order + `
 		LIMIT $2 OFFSET $3;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userId, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags), fq.Since, fq.Until)
+	createdAt, id := cursorPosition(fq.Cursor)
+	args := append([]any{userId, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags), fq.Since, fq.Until, createdAt, id}, scopeArgs...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, err
@@ -163,6 +390,8 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginatedF
 
 	for rows.Next() {
 		var p PostWithMetadata
+		var reactionCounts []byte
+		var myReaction sql.NullString
 		err := rows.Scan(
 			&p.ID,
 			&p.Title,
@@ -174,14 +403,21 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginatedF
 			&p.Version,
 			&p.CommentsCount,
 			&p.User.Username,
+			&reactionCounts,
+			&myReaction,
 		)
 
 		if err != nil {
 			return nil, err
 		}
 
+		if p.Reactions, err = decodeReactionCounts(reactionCounts); err != nil {
+			return nil, err
+		}
+		p.MyReaction = myReaction.String
+
 		feed = append(feed, p)
 	}
 
-	return feed, nil
+	return inListOrder(feed, fq.Cursor), rows.Err()
 }
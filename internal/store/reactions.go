@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reactor is a user in the list of reactions to a post.
+type Reactor struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	Reaction  string    `json:"reaction"`
+	ReactedAt time.Time `json:"reacted_at"`
+}
+
+func (r Reactor) Cursor() Cursor {
+	return Cursor{CreatedAt: r.ReactedAt, ID: r.ID}
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// React sets the reaction of the user to the post, in place of the one the
+// user had. It returns ErrorNotFound when the post does not exist.
+func (s *ReactionStore) React(ctx context.Context, postId, userId int64, reaction string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var previous string
+		err := tx.QueryRowContext(ctx, `
+			SELECT reaction FROM post_reactions
+			WHERE post_id = $1 AND user_id = $2
+			FOR UPDATE
+		`, postId, userId).Scan(&previous)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if previous == reaction {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO post_reactions (post_id, user_id, reaction)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (post_id, user_id)
+			DO UPDATE SET reaction = EXCLUDED.reaction, created_at = NOW()
+		`, postId, userId, reaction)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrorNotFound
+			}
+			return err
+		}
+
+		// the counters are moved in place, so reactions to the same post
+		// only wait on each other for this update
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts
+			SET reaction_counts = jsonb_set(
+				CASE WHEN $2::text = '' THEN reaction_counts
+				ELSE jsonb_set(reaction_counts, ARRAY[$2::text], to_jsonb(COALESCE((reaction_counts->>$2::text)::int, 0) - 1))
+				END,
+				ARRAY[$3::text], to_jsonb(COALESCE((reaction_counts->>$3::text)::int, 0) + 1)
+			)
+			WHERE id = $1
+		`, postId, previous, reaction)
+		return err
+	})
+}
+
+// Unreact takes back the reaction of the user to the post. It returns
+// ErrorNotFound when the user has not reacted to it.
+func (s *ReactionStore) Unreact(ctx context.Context, postId, userId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var reaction string
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM post_reactions
+			WHERE post_id = $1 AND user_id = $2
+			RETURNING reaction
+		`, postId, userId).Scan(&reaction)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts
+			SET reaction_counts = jsonb_set(reaction_counts, ARRAY[$2::text], to_jsonb((reaction_counts->>$2::text)::int - 1))
+			WHERE id = $1
+		`, postId, reaction)
+		return err
+	})
+}
+
+// GetReactors lists the users who reacted to the post that the viewer may
+// see, newest reaction first.
+func (s *ReactionStore) GetReactors(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Reactor, error) {
+	page, order := keysetPage(q.Cursor, "r.created_at", "r.user_id", true, "$3", "$4")
+	query := `
+		SELECT u.id, u.username, u.avatar_url, r.reaction, r.created_at
+		FROM post_reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.post_id = $1
+		AND ` + canViewAuthor("r.user_id", "$2") + `
+		AND ` + page + `
+		ORDER BY ` + order + `
+		LIMIT $5
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	createdAt, id := q.position()
+	rows, err := s.db.QueryContext(ctx, query, postId, viewerId, createdAt, id, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactors := []Reactor{}
+	for rows.Next() {
+		var r Reactor
+		if err := rows.Scan(&r.ID, &r.Username, &r.AvatarURL, &r.Reaction, &r.ReactedAt); err != nil {
+			return nil, err
+		}
+		reactors = append(reactors, r)
+	}
+
+	return inListOrder(reactors, q.Cursor), rows.Err()
+}
+
+// ReactionCounts counts the reactions to a post by reaction.
+type ReactionCounts map[string]int
+
+// Total returns the number of reactions of any kind.
+func (c ReactionCounts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// decodeReactionCounts reads the counters of a post. Counters left at zero
+// by taken back reactions are dropped.
+func decodeReactionCounts(data []byte) (ReactionCounts, error) {
+	counts := ReactionCounts{}
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return nil, err
+	}
+
+	for reaction, n := range counts {
+		if n <= 0 {
+			delete(counts, reaction)
+		}
+	}
+	return counts, nil
+}
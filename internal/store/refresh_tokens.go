@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrorTokenReused = errors.New("refresh token reused")
+
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	FamilyId  string     `json:"family_id"`
+	Token     string     `json:"-"`
+	Expiry    time.Time  `json:"expiry"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token)
+	})
+}
+
+func (s *RefreshTokenStore) GetByToken(ctx context.Context, hashToken string) (*RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token, expiry, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token := &RefreshToken{}
+	err := s.db.QueryRowContext(ctx, query, hashToken).Scan(
+		&token.ID,
+		&token.UserId,
+		&token.FamilyId,
+		&token.Token,
+		&token.Expiry,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// Rotate revokes the current token and stores its replacement in a single
+// transaction. It returns ErrorTokenReused when the current token has
+// already been revoked, e.g. by a concurrent refresh.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, current, next *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE id = $1 AND revoked_at IS NULL
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, current.ID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrorTokenReused
+		}
+
+		return s.create(ctx, tx, next)
+	})
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyId string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, familyId)
+	return err
+}
+
+func (s *RefreshTokenStore) RevokeAllByUser(ctx context.Context, userId int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		token.UserId,
+		token.FamilyId,
+		token.Token,
+		token.Expiry,
+	).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+}
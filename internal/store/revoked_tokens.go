@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// expired tokens are rejected by signature validation anyway
+		if _, err := tx.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expiry < NOW()`); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO revoked_tokens (jti, expiry)
+			VALUES ($1, $2)
+			ON CONFLICT (jti) DO NOTHING
+		`
+		_, err := tx.ExecContext(ctx, query, jti, expiry)
+		return err
+	})
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT 1 FROM revoked_tokens WHERE jti = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var found int
+	err := s.db.QueryRowContext(ctx, query, jti).Scan(&found)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
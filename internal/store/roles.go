@@ -3,13 +3,26 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
 )
 
+var ErrorRoleInUse = errors.New("role is assigned to users")
+
 type Role struct {
 	Id          int64
 	Name        string
 	Level       int
 	Description string
+	Permissions []string
+}
+
+// Permission is a named right that roles grant, e.g. post.delete.any.
+type Permission struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
 
 type RolesStore struct {
@@ -27,3 +40,156 @@ func (s *RolesStore) GetByName(ctx context.Context, name string) (*Role, error)
 
 	return role, nil
 }
+
+// List returns every role with the names of its permissions.
+func (s *RolesStore) List(ctx context.Context) ([]Role, error) {
+	query := `
+		SELECT r.id, r.name, r.level, COALESCE(r.description, ''),
+			COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id
+		ORDER BY r.level, r.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.Id, &role.Name, &role.Level, &role.Description, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (s *RolesStore) Create(ctx context.Context, role *Role) error {
+	query := `
+		INSERT INTO roles (name, level, description)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, role.Name, role.Level, role.Description).Scan(&role.Id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrorAlreadyExists
+		}
+		return err
+	}
+
+	role.Permissions = []string{}
+	return nil
+}
+
+// Delete removes the role. It returns ErrorRoleInUse while users still have
+// it.
+func (s *RolesStore) Delete(ctx context.Context, name string) error {
+	query := `DELETE FROM roles WHERE name = $1`
+
+	err := execOne(ctx, s.db, query, name)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrorRoleInUse
+	}
+	return err
+}
+
+// HasPermission reports whether the role grants the permission.
+func (s *RolesStore) HasPermission(ctx context.Context, roleName, permission string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM role_permissions rp
+			JOIN roles r ON r.id = rp.role_id
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE r.name = $1 AND p.name = $2
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var granted bool
+	err := s.db.QueryRowContext(ctx, query, roleName, permission).Scan(&granted)
+	return granted, err
+}
+
+func (s *RolesStore) ListPermissions(ctx context.Context) ([]Permission, error) {
+	query := `SELECT id, name, description FROM permissions ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, rows.Err()
+}
+
+// Grant adds the permission to the role. Granting it twice is not an error.
+// It returns ErrorNotFound when the role or the permission does not exist.
+func (s *RolesStore) Grant(ctx context.Context, roleName, permission string) error {
+	query := `
+	WITH target AS (
+		SELECT r.id AS role_id, p.id AS permission_id
+		FROM roles r, permissions p
+		WHERE r.name = $1 AND p.name = $2
+	), granted AS (
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT role_id, permission_id FROM target
+		ON CONFLICT DO NOTHING
+	)
+	SELECT EXISTS (SELECT 1 FROM target)
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, query, roleName, permission).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// Revoke removes the permission from the role.
+func (s *RolesStore) Revoke(ctx context.Context, roleName, permission string) error {
+	query := `
+		DELETE FROM role_permissions rp
+		USING roles r, permissions p
+		WHERE rp.role_id = r.id AND rp.permission_id = p.id
+		AND r.name = $1 AND p.name = $2
+	`
+
+	return execOne(ctx, s.db, query, roleName, permission)
+}
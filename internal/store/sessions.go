@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SessionTouchInterval limits how often the last seen time of a session is
+// written, as every authenticated request touches its session.
+var SessionTouchInterval = time.Minute
+
+// Session is a login on one device. Its id is the id of the refresh token
+// family issued for the login, so it lives as long as the family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserId     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+func (s *SessionStore) Create(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, last_seen_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		session.ID,
+		session.UserId,
+		session.UserAgent,
+		session.IP,
+	).Scan(
+		&session.CreatedAt,
+		&session.LastSeenAt,
+	)
+}
+
+func (s *SessionStore) GetByUser(ctx context.Context, userId int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserId,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch checks that the session still exists and updates its last seen time
+// when it is older than SessionTouchInterval. It returns ErrorNotFound for
+// deleted sessions.
+func (s *SessionStore) Touch(ctx context.Context, id string, userId int64) error {
+	query := `
+		WITH touched AS (
+			UPDATE sessions
+			SET last_seen_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND last_seen_at < $3
+		)
+		SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, query, id, userId, time.Now().Add(-SessionTouchInterval)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// Delete ends the session and drops its refresh tokens, so it can neither
+// be used nor refreshed anymore.
+func (s *SessionStore) Delete(ctx context.Context, id string, userId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrorNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE family_id = $1`, id)
+		return err
+	})
+}
+
+func (s *SessionStore) DeleteAllByUser(ctx context.Context, userId int64) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+// DeleteExpired deletes the sessions that cannot be refreshed anymore, as
+// none of the refresh tokens of their family is still valid. Sessions just
+// created are kept, as their first refresh token is stored after them.
+func (s *SessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM sessions s
+		WHERE s.created_at < NOW() - interval '1 hour'
+		AND NOT EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.family_id = s.id AND rt.revoked_at IS NULL AND rt.expiry > NOW()
+		)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
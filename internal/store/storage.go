@@ -10,6 +10,7 @@ import (
 var (
 	ErrorNotFound        = errors.New("record not found")
 	ErrorAlreadyExists   = errors.New("record already exists")
+	ErrorBlocked         = errors.New("blocked by the user")
 	QueryTimeoutDuration = 5 * time.Second
 )
 
@@ -17,41 +18,157 @@ type Storage struct {
 	Posts interface {
 		Create(context.Context, *Post) error
 		GetById(context.Context, int64) (Post, error)
+		GetByUser(context.Context, int64) ([]Post, error)
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetExploreFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetTimelineFeed(ctx context.Context, userId int64, timeline []TimelineEntry, celebrities []int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetTimeline(ctx context.Context, userId int64, threshold, limit int) ([]TimelineEntry, error)
+		GetAuthorAffinities(ctx context.Context, viewerId int64, authorIds []int64) (map[int64]AuthorAffinity, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
 		GetById(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
+		GetCounts(context.Context, int64) (*UserCounts, error)
+		Search(ctx context.Context, viewerId int64, q UserSearchQuery) ([]UserSearchResult, error)
 		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		CreateFromIdentity(ctx context.Context, user *User, identity *UserIdentity, token string, invitationExp time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		IncrementTokenVersion(context.Context, int64) error
+		CreatePasswordReset(ctx context.Context, userId int64, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token string, password *Password) (*User, error)
+		ReplaceInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error)
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		Update(context.Context, *User) error
+		ChangePassword(ctx context.Context, userId int64, password *Password, sessionId string) error
+		CreateEmailChange(ctx context.Context, userId int64, email, token string, exp time.Duration) error
+		ConfirmEmailChange(ctx context.Context, token string) (*User, error)
+		List(context.Context, AdminUserQuery) ([]User, error)
+		SetRole(ctx context.Context, userId int64, roleName string) error
+		Suspend(ctx context.Context, userId int64, until time.Time, reason string, moderatorId int64) error
+		Ban(ctx context.Context, userId int64, reason string, moderatorId int64) error
+		Reactivate(context.Context, int64) error
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
 		GetById(context.Context, int64) (*Comment, error)
-		GetByPostId(context.Context, int64) ([]Comment, error)
+		GetByPostId(ctx context.Context, postId, viewerId int64) ([]Comment, error)
+		ListByPostId(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Comment, error)
+		GetByUser(context.Context, int64) ([]Comment, error)
 		Update(ctx context.Context, comment *Comment) error
 		Delete(ctx context.Context, commentId, userId int64) error
 	}
+	Reactions interface {
+		React(ctx context.Context, postId, userId int64, reaction string) error
+		Unreact(ctx context.Context, postId, userId int64) error
+		GetReactors(ctx context.Context, postId, viewerId int64, q PaginatedKeysetQuery) ([]Reactor, error)
+	}
 	Followers interface {
 		FollowUser(ctx context.Context, followerId, userId int64) error
 		UnfollowUser(ctx context.Context, followerId, userId int64) error
+		GetFollowers(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error)
+		GetFollowing(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error)
+		IsFollowing(ctx context.Context, followerId, userId int64) (bool, error)
+		GetRelationship(ctx context.Context, userId, otherId int64) (*Relationship, error)
+		RequestFollow(ctx context.Context, requesterId, userId int64) error
+		GetFollowRequests(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]FollowEntry, error)
+		ApproveFollowRequest(ctx context.Context, userId, requesterId int64) error
+		ApproveFollowRequests(ctx context.Context, userId int64) ([]int64, error)
+		DeleteFollowRequest(ctx context.Context, userId, requesterId int64) error
+		GetFollowerIds(context.Context, int64) ([]int64, error)
+		GetCelebrities(ctx context.Context, threshold int) ([]int64, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerId, blockedId int64) error
+		Unblock(ctx context.Context, blockerId, blockedId int64) error
+		GetBlocked(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error)
+		IsBlocked(ctx context.Context, userId, otherId int64) (bool, error)
+	}
+	Mutes interface {
+		Mute(ctx context.Context, muterId, mutedId int64) error
+		Unmute(ctx context.Context, muterId, mutedId int64) error
+		GetMuted(ctx context.Context, userId int64, q PaginatedKeysetQuery) ([]UserEntry, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		List(context.Context) ([]Role, error)
+		Create(context.Context, *Role) error
+		Delete(context.Context, string) error
+		HasPermission(ctx context.Context, roleName, permission string) (bool, error)
+		ListPermissions(context.Context) ([]Permission, error)
+		Grant(ctx context.Context, roleName, permission string) error
+		Revoke(ctx context.Context, roleName, permission string) error
+	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		GetByToken(context.Context, string) (*RefreshToken, error)
+		Rotate(ctx context.Context, current, next *RefreshToken) error
+		RevokeFamily(ctx context.Context, familyId string) error
+		RevokeAllByUser(ctx context.Context, userId int64) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, expiry time.Time) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+	}
+	TwoFactor interface {
+		SetSecret(ctx context.Context, userId int64, secret string) error
+		GetSecret(context.Context, int64) (string, error)
+		Enable(ctx context.Context, userId int64, recoveryCodes []string) error
+		Disable(context.Context, int64) error
+		UseTOTPStep(ctx context.Context, userId int64, step int64) error
+		UseRecoveryCode(ctx context.Context, userId int64, code string) error
+		CreateChallenge(ctx context.Context, userId int64, token string, exp time.Duration) error
+		ConsumeChallenge(context.Context, string) (int64, error)
+	}
+	APIKeys interface {
+		Create(context.Context, *APIKey) error
+		GetByKey(context.Context, string) (*APIKey, error)
+		GetByUser(context.Context, int64) ([]APIKey, error)
+		Delete(ctx context.Context, keyId, userId int64) error
+	}
+	UserIdentities interface {
+		GetByProvider(ctx context.Context, provider, subject string) (*UserIdentity, error)
+		Create(context.Context, *UserIdentity) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session) error
+		GetByUser(context.Context, int64) ([]Session, error)
+		Touch(ctx context.Context, id string, userId int64) error
+		Delete(ctx context.Context, id string, userId int64) error
+		DeleteAllByUser(context.Context, int64) error
+		DeleteExpired(context.Context) (int64, error)
+	}
+	Exports interface {
+		Create(context.Context, *Export) error
+		GetByUser(context.Context, int64) ([]Export, error)
+		Claim(context.Context) (*Export, error)
+		Complete(ctx context.Context, export *Export, exp time.Duration) error
+		Fail(context.Context, string) error
+		GetArchive(context.Context, string) (*Export, error)
+		DeleteExpired(context.Context) (int64, error)
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UserStore{db: db},
-		Comments:  &CommentsStore{db: db},
-		Followers: &FollowerStore{db: db},
-		Roles:     &RolesStore{db: db},
+		Posts:          &PostStore{db: db},
+		Users:          &UserStore{db: db},
+		Comments:       &CommentsStore{db: db},
+		Reactions:      &ReactionStore{db: db},
+		Followers:      &FollowerStore{db: db},
+		Blocks:         &BlockStore{db: db},
+		Mutes:          &MuteStore{db: db},
+		Roles:          &RolesStore{db: db},
+		RefreshTokens:  &RefreshTokenStore{db: db},
+		RevokedTokens:  &RevokedTokenStore{db: db},
+		TwoFactor:      &TwoFactorStore{db: db},
+		APIKeys:        &APIKeyStore{db: db},
+		UserIdentities: &UserIdentityStore{db: db},
+		Sessions:       &SessionStore{db: db},
+		Exports:        &ExportStore{db: db},
 	}
 }
 
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type TwoFactorStore struct {
+	db *sql.DB
+}
+
+// SetSecret stores the sealed TOTP secret of a pending enrollment. It returns
+// ErrorAlreadyExists when two-factor authentication is already enabled.
+func (s *TwoFactorStore) SetSecret(ctx context.Context, userId int64, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_last_step = NULL
+		WHERE id = $2 AND two_factor_enabled = false
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, secret, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorAlreadyExists
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) GetSecret(ctx context.Context, userId int64) (string, error) {
+	query := `SELECT totp_secret FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var secret sql.NullString
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(&secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrorNotFound
+		default:
+			return "", err
+		}
+	}
+
+	if !secret.Valid {
+		return "", ErrorNotFound
+	}
+
+	return secret.String, nil
+}
+
+// Enable turns on two-factor authentication and replaces the recovery codes
+// with the given hashed ones.
+func (s *TwoFactorStore) Enable(ctx context.Context, userId int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET two_factor_enabled = true
+			WHERE id = $1 AND totp_secret IS NOT NULL
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrorNotFound
+		}
+
+		if err := s.deleteRecoveryCodes(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO users_recovery_codes (user_id, code) VALUES ($1, $2)`
+		for _, code := range recoveryCodes {
+			if _, err := tx.ExecContext(ctx, query, userId, code); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET two_factor_enabled = false, totp_secret = NULL, totp_last_step = NULL
+			WHERE id = $1
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM users_two_factor_challenges WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+
+		return s.deleteRecoveryCodes(ctx, tx, userId)
+	})
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code. It returns
+// ErrorNotFound when a code of the same or a later step was accepted before,
+// so that a code cannot be replayed while it is still valid.
+func (s *TwoFactorStore) UseTOTPStep(ctx context.Context, userId int64, step int64) error {
+	query := `
+		UPDATE users
+		SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks the hashed code as used. It returns ErrorNotFound
+// when the code does not exist or was used before.
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userId int64, code string) error {
+	query := `
+		UPDATE users_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) CreateChallenge(ctx context.Context, userId int64, token string, exp time.Duration) error {
+	query := `
+		INSERT INTO users_two_factor_challenges (token, user_id, expiry)
+		VALUES ($1, $2, $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, userId, time.Now().Add(exp))
+	return err
+}
+
+// ConsumeChallenge deletes the challenge of the plain token and returns the
+// user it was issued to. A challenge can only be answered once.
+func (s *TwoFactorStore) ConsumeChallenge(ctx context.Context, token string) (int64, error) {
+	query := `
+		DELETE FROM users_two_factor_challenges
+		WHERE token = $1 AND expiry > $2
+		RETURNING user_id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	var userId int64
+	err := s.db.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrorNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userId, nil
+}
+
+func (s *TwoFactorStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+		DELETE FROM users_recovery_codes
+		WHERE user_id = $1
+		`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	return err
+}
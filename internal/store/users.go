@@ -6,8 +6,10 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,14 +19,45 @@ var (
 )
 
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleId    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	ID               int64    `json:"id"`
+	Username         string   `json:"username"`
+	Email            string   `json:"email"`
+	Password         Password `json:"-"`
+	CreatedAt        string   `json:"created_at"`
+	IsActive         bool     `json:"is_active"`
+	RoleId           int64    `json:"role_id"`
+	Role             Role     `json:"role"`
+	TokenVersion     int      `json:"-"`
+	TwoFactorEnabled bool     `json:"two_factor_enabled"`
+	Bio              string   `json:"bio"`
+	AvatarURL        string   `json:"avatar_url"`
+	Website          string   `json:"website"`
+	Location         string   `json:"location"`
+	IsPrivate        bool     `json:"is_private"`
+	// SuspendedUntil is set while a moderator has made the account read-only.
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	SuspendedBy      *int64     `json:"suspended_by,omitempty"`
+	// BannedAt is set once a moderator has banned the account for good.
+	BannedAt  *time.Time `json:"banned_at,omitempty"`
+	BanReason string     `json:"ban_reason,omitempty"`
+	BannedBy  *int64     `json:"banned_by,omitempty"`
+}
+
+// IsSuspended reports whether the account is suspended at the given time.
+func (u *User) IsSuspended(now time.Time) bool {
+	return u.SuspendedUntil != nil && u.SuspendedUntil.After(now)
+}
+
+func (u *User) IsBanned() bool {
+	return u.BannedAt != nil
+}
+
+// UserCounts are the public counters of a profile.
+type UserCounts struct {
+	Followers int64 `json:"followers_count"`
+	Following int64 `json:"following_count"`
+	Posts     int64 `json:"posts_count"`
 }
 
 type Password struct {
@@ -51,6 +84,12 @@ func (p *Password) Hash() []byte {
 	return p.hash
 }
 
+// IsSet reports whether there is a password. Accounts created with an
+// identity provider have none.
+func (p *Password) IsSet() bool {
+	return len(p.hash) > 0
+}
+
 type UserStore struct {
 	db *sql.DB
 }
@@ -79,43 +118,68 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	)
 
 	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
-			return ErrorDuplicatedEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
-			return ErrorDuplicatedUsername
-		default:
-			return err
-		}
+		return duplicatedUserError(err)
 	}
 	return nil
 }
 
-func (s *UserStore) GetById(ctx context.Context, id int64) (*User, error) {
-	query := `
-		SELECT users.id, users.username, users.email, users.created_at, users.is_active, roles.*
-		FROM users
-		JOIN roles ON users.role_id = roles.id
-		WHERE users.id = $1
-	`
+// duplicatedUserError maps violations of the unique email and username
+// constraints to their errors.
+func duplicatedUserError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		return ErrorDuplicatedEmail
+	case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		return ErrorDuplicatedUsername
+	default:
+		return err
+	}
+}
 
-	var user User
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		id,
-	).Scan(
+const userColumns = `
+	users.id, users.username, users.email, users.created_at, users.is_active, users.token_version, users.two_factor_enabled,
+	users.bio, users.avatar_url, users.website, users.location, users.is_private,
+	users.suspended_until, users.suspension_reason, users.suspended_by,
+	users.banned_at, users.ban_reason, users.banned_by, roles.*
+`
+
+func scanUser(row interface{ Scan(...any) error }, user *User) error {
+	return row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.TokenVersion,
+		&user.TwoFactorEnabled,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Website,
+		&user.Location,
+		&user.IsPrivate,
+		&user.SuspendedUntil,
+		&user.SuspensionReason,
+		&user.SuspendedBy,
+		&user.BannedAt,
+		&user.BanReason,
+		&user.BannedBy,
 		&user.RoleId,
 		&user.Role.Name,
 		&user.Role.Level,
 		&user.Role.Description,
 	)
+}
+
+func (s *UserStore) GetById(ctx context.Context, id int64) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		JOIN roles ON users.role_id = roles.id
+		WHERE users.id = $1
+	`
 
+	var user User
+	err := scanUser(s.db.QueryRowContext(ctx, query, id), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return &User{}, ErrorNotFound
@@ -126,9 +190,94 @@ func (s *UserStore) GetById(ctx context.Context, id int64) (*User, error) {
 	return &user, nil
 }
 
+// GetCounts returns the follower, following and post counts of the user in
+// a single round trip.
+func (s *UserStore) GetCounts(ctx context.Context, userId int64) (*UserCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = $1),
+			(SELECT COUNT(*) FROM posts WHERE user_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var counts UserCounts
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(
+		&counts.Followers,
+		&counts.Following,
+		&counts.Posts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+// UserSearchResult is a user found in the directory.
+type UserSearchResult struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+	Bio       string `json:"bio"`
+	IsPrivate bool   `json:"is_private"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search looks up active users by username, best match first, leaving out
+// users who blocked the viewer or were blocked by them. Email addresses are
+// never matched, so the directory cannot be used to find out whose they are.
+// Autocomplete matches username prefixes and lists users the viewer follows
+// first.
+func (s *UserStore) Search(ctx context.Context, viewerId int64, sq UserSearchQuery) ([]UserSearchResult, error) {
+	pattern := likeEscaper.Replace(sq.Search) + "%"
+	match := `(u.username ILIKE $3 OR u.username % $2)`
+	order := `similarity(u.username, $2) DESC, u.id`
+	if sq.Mode == "autocomplete" {
+		match = `u.username ILIKE $3`
+		order = `EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1) DESC,
+			similarity(u.username, $2) DESC, u.id`
+	} else {
+		pattern = "%" + pattern
+	}
+
+	query := `
+		SELECT u.id, u.username, u.avatar_url, u.bio, u.is_private
+		FROM users u
+		WHERE u.is_active
+		AND ` + match + `
+		AND NOT ` + isBlocked("u.id", "$1") + `
+		ORDER BY ` + order + `
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, sq.Search, pattern, sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.Username, &u.AvatarURL, &u.Bio, &u.IsPrivate); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at
+		SELECT id, username, email, password, created_at, token_version, two_factor_enabled, banned_at, ban_reason
 		FROM users
 		WHERE email = $1 AND is_active = true
 	`
@@ -147,6 +296,10 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.TokenVersion,
+		&user.TwoFactorEnabled,
+		&user.BannedAt,
+		&user.BanReason,
 	)
 	if err != nil {
 		switch err {
@@ -174,6 +327,36 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 	})
 }
 
+// CreateFromIdentity creates a user signing up through an OAuth provider
+// together with the link to the external identity. Such users have no
+// password. The account stays inactive and gets the invitation when a token
+// is given, otherwise it is active right away.
+func (s *UserStore) CreateFromIdentity(ctx context.Context, user *User, identity *UserIdentity, token string, invitationExp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if user.Password.hash == nil {
+			user.Password.hash = []byte{}
+		}
+
+		if err := s.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user.IsActive = token == ""
+		if user.IsActive {
+			if err := s.update(ctx, tx, user); err != nil {
+				return err
+			}
+		} else {
+			if err := s.createUserInvitation(ctx, tx, token, invitationExp, user.ID); err != nil {
+				return err
+			}
+		}
+
+		identity.UserId = user.ID
+		return createUserIdentity(ctx, tx, identity)
+	})
+}
+
 func (s *UserStore) Activate(ctx context.Context, token string) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		user, err := s.getUserFromInvitation(ctx, tx, token)
@@ -195,8 +378,111 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 	})
 }
 
+// ReplaceInvitation swaps the pending invitation of a not yet activated
+// account for a new one. It returns ErrorNotFound when there is no such account.
+func (s *UserStore) ReplaceInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, username, email, created_at
+			FROM users
+			WHERE email = $1 AND is_active = false
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		user = &User{}
+		err := tx.QueryRowContext(ctx, query, email).Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.deleteUserInvitation(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationExp, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteExpiredInvitations removes accounts that were never activated and
+// whose invitations have all expired, which frees their email and username.
+func (s *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM users
+			WHERE is_active = false
+			AND EXISTS (SELECT 1 FROM users_invitations i WHERE i.user_id = users.id)
+			AND NOT EXISTS (SELECT 1 FROM users_invitations i WHERE i.user_id = users.id AND i.expiry > $1)
+			RETURNING id
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, time.Now())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		query = `DELETE FROM users_invitations WHERE user_id = ANY($1)`
+		if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			return err
+		}
+
+		deleted = int64(len(ids))
+		return nil
+	})
+
+	return deleted, err
+}
+
+// Update saves the username, profile fields and privacy of the user.
+func (s *UserStore) Update(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, user)
+	})
+}
+
+// Delete removes the user together with their posts and comments, and the
+// comments left under their posts.
 func (s *UserStore) Delete(ctx context.Context, userId int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteContent(ctx, tx, userId); err != nil {
+			return err
+		}
 		if err := s.delete(ctx, tx, userId); err != nil {
 			return err
 		}
@@ -207,6 +493,347 @@ func (s *UserStore) Delete(ctx context.Context, userId int64) error {
 	})
 }
 
+// IncrementTokenVersion invalidates every access token issued to the user
+// so far, as tokens carry the version they were issued with.
+func (s *UserStore) IncrementTokenVersion(ctx context.Context, userId int64) error {
+	query := `
+		UPDATE users
+		SET token_version = token_version + 1
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// CreatePasswordReset stores the hashed reset token, replacing any reset
+// requested earlier so that only the newest link works.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userId int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deletePasswordResets(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO users_password_resets (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userId, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ResetPassword sets a new password for the owner of the plain reset token.
+// The token is consumed and all of the user's sessions are invalidated.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, password *Password) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE users
+			SET password = $1, token_version = token_version + 1
+			WHERE id = $2
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, password.hash, user.ID); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE user_id = $1 AND revoked_at IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, query, user.ID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, user.ID); err != nil {
+			return err
+		}
+
+		return s.deletePasswordResets(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangePassword sets a new password and signs the user out of every session
+// except the one making the change.
+func (s *UserStore) ChangePassword(ctx context.Context, userId int64, password *Password, sessionId string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE users SET password = $1 WHERE id = $2`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, password.hash, userId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrorNotFound
+		}
+
+		query = `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE user_id = $1 AND family_id::text <> $2 AND revoked_at IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, query, userId, sessionId); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM sessions WHERE user_id = $1 AND id::text <> $2`
+		_, err = tx.ExecContext(ctx, query, userId, sessionId)
+		return err
+	})
+}
+
+// CreateEmailChange stores the hashed confirmation token of a pending email
+// change, replacing any change requested earlier.
+func (s *UserStore) CreateEmailChange(ctx context.Context, userId int64, email, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteEmailChanges(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO users_email_changes (token, user_id, email, expiry)
+			VALUES ($1, $2, $3, $4)
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userId, email, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ConfirmEmailChange applies the pending email change of the plain token and
+// returns the user with the new address.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT users.id, users.username, users_email_changes.email, users.created_at
+			FROM users
+			JOIN users_email_changes ON users.id = users_email_changes.user_id
+			WHERE users_email_changes.token = $1 AND users_email_changes.expiry > $2
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+		user = &User{}
+		err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `UPDATE users SET email = $1 WHERE id = $2`
+		if _, err := tx.ExecContext(ctx, query, user.Email, user.ID); err != nil {
+			return duplicatedUserError(err)
+		}
+
+		return s.deleteEmailChanges(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// List returns the accounts matching the filters, newest first.
+func (s *UserStore) List(ctx context.Context, aq AdminUserQuery) ([]User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		JOIN roles ON users.role_id = roles.id
+		WHERE ($1 = '' OR users.username ILIKE '%' || $1 || '%' OR users.email ILIKE '%' || $1 || '%')
+		AND ($2 = '' OR roles.name = $2)
+		AND ($3 <> 'active' OR (users.is_active AND users.banned_at IS NULL
+			AND (users.suspended_until IS NULL OR users.suspended_until <= NOW())))
+		AND ($3 <> 'inactive' OR NOT users.is_active)
+		AND ($3 <> 'suspended' OR users.suspended_until > NOW())
+		AND ($3 <> 'banned' OR users.banned_at IS NOT NULL)
+		ORDER BY users.created_at DESC, users.id DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, likeEscaper.Replace(aq.Search), aq.Role, aq.Status, aq.Limit, aq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// SetRole gives the user the named role. It returns ErrorNotFound when the
+// user or the role does not exist.
+func (s *UserStore) SetRole(ctx context.Context, userId int64, roleName string) error {
+	query := `
+		UPDATE users
+		SET role_id = roles.id
+		FROM roles
+		WHERE users.id = $1 AND roles.name = $2
+	`
+
+	return execOne(ctx, s.db, query, userId, roleName)
+}
+
+// Suspend makes the account read-only until the given time.
+func (s *UserStore) Suspend(ctx context.Context, userId int64, until time.Time, reason string, moderatorId int64) error {
+	query := `
+		UPDATE users
+		SET suspended_until = $2, suspension_reason = $3, suspended_by = $4
+		WHERE id = $1
+	`
+
+	return execOne(ctx, s.db, query, userId, until, reason, moderatorId)
+}
+
+// Ban shuts the account out until it is reactivated.
+func (s *UserStore) Ban(ctx context.Context, userId int64, reason string, moderatorId int64) error {
+	query := `
+		UPDATE users
+		SET banned_at = NOW(), ban_reason = $2, banned_by = $3
+		WHERE id = $1
+	`
+
+	return execOne(ctx, s.db, query, userId, reason, moderatorId)
+}
+
+// Reactivate lifts the suspension and the ban of the user.
+func (s *UserStore) Reactivate(ctx context.Context, userId int64) error {
+	query := `
+		UPDATE users
+		SET suspended_until = NULL, suspension_reason = '', suspended_by = NULL,
+			banned_at = NULL, ban_reason = '', banned_by = NULL
+		WHERE id = $1
+	`
+
+	return execOne(ctx, s.db, query, userId)
+}
+
+func (s *UserStore) deleteEmailChanges(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+		DELETE FROM users_email_changes
+		WHERE user_id = $1
+		`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	return err
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT users.id, users.username, users.email, users.created_at
+		FROM users
+		JOIN users_password_resets ON users.id = users_password_resets.user_id
+		WHERE users_password_resets.token = $1 AND users_password_resets.expiry > $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+	user := &User{}
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		hashToken,
+		time.Now(),
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+		DELETE FROM users_password_resets
+		WHERE user_id = $1
+		`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	return err
+}
+
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
 		SELECT users.id, users.username, users.email, users.created_at
@@ -266,8 +893,8 @@ func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, is_active = $3
-		WHERE id = $4
+		SET username = $1, email = $2, is_active = $3, bio = $4, avatar_url = $5, website = $6, location = $7, is_private = $8
+		WHERE id = $9
 		`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -278,11 +905,16 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 		user.Username,
 		user.Email,
 		user.IsActive,
+		user.Bio,
+		user.AvatarURL,
+		user.Website,
+		user.Location,
+		user.IsPrivate,
 		user.ID,
 	)
 
 	if err != nil {
-		return err
+		return duplicatedUserError(err)
 	}
 	return nil
 }
@@ -318,3 +950,31 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userId int64) error
 	)
 	return err
 }
+
+func (s *UserStore) deleteContent(ctx context.Context, tx *sql.Tx, userId int64) error {
+	// the reactions themselves go with the account, their counts have to
+	// be taken back first
+	reactions := `
+		UPDATE posts p
+		SET reaction_counts = jsonb_set(p.reaction_counts, ARRAY[r.reaction], to_jsonb((p.reaction_counts->>r.reaction)::int - 1))
+		FROM post_reactions r
+		WHERE r.user_id = $1 AND r.post_id = p.id AND p.user_id != $1
+	`
+	query := `
+		DELETE FROM comments
+		WHERE user_id = $1 OR post_id IN (SELECT id FROM posts WHERE user_id = $1)
+		`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, reactions, userId); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE user_id = $1`, userId)
+	return err
+}
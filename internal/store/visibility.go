@@ -0,0 +1,39 @@
+package store
+
+import "fmt"
+
+// canViewAuthor returns an SQL condition that holds when the viewer may see
+// content written by the author: the viewer is the author, or neither user
+// blocked the other and the author's account is public or followed by the
+// viewer. Both arguments are SQL expressions, e.g. a column and a
+// placeholder.
+func canViewAuthor(author, viewer string) string {
+	return fmt.Sprintf(`(
+		%[1]s = %[2]s
+		OR (
+			NOT %[3]s
+			AND (
+				NOT EXISTS (SELECT 1 FROM users pu WHERE pu.id = %[1]s AND pu.is_private)
+				OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = %[1]s AND vf.follower_id = %[2]s)
+			)
+		)
+	)`, author, viewer, isBlocked(author, viewer))
+}
+
+// isBlocked returns an SQL condition that holds when either user blocked the
+// other.
+func isBlocked(a, b string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = %[1]s AND ub.blocked_id = %[2]s)
+		OR (ub.blocker_id = %[2]s AND ub.blocked_id = %[1]s)
+	)`, a, b)
+}
+
+// isMuted returns an SQL condition that holds when the viewer muted the
+// author.
+func isMuted(author, viewer string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_mutes um WHERE um.muter_id = %[2]s AND um.muted_id = %[1]s
+	)`, author, viewer)
+}